@@ -0,0 +1,503 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/validator"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name  string
+		Email string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Email = app.readString(qs, "email", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "email", "created_at", "-id", "-name", "-email", "-created_at"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	users, metadata, err := app.models.Users.GetAll(input.Name, input.Email, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	user, err := app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.writeUserAccess(w, r, user)
+}
+
+func (app *application) updateUserActivationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	user, err := app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Activated *bool `json:"activated"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if v.Check(input.Activated != nil, "activated", "validation.required"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	user.Activated = *input.Activated
+	action := data.AuditUserDeactivated
+	if user.Activated {
+		action = data.AuditUserActivated
+	}
+	err = app.models.Users.UpdateAudited(user, app.auditEntry(r, action, user.ID, nil))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.invalidateUser(user.ID)
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) grantUserPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserPermissions(w, r, data.AuditPermissionsGranted)
+}
+
+func (app *application) revokeUserPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserPermissions(w, r, data.AuditPermissionsRevoked)
+}
+
+func (app *application) changeUserPermissions(w http.ResponseWriter, r *http.Request, action string) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	user, err := app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Codes []string `json:"codes"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidatePermissionCodes(v, input.Codes, known); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	entry := app.auditEntry(r, action, user.ID, map[string]interface{}{"codes": input.Codes})
+	switch action {
+	case data.AuditPermissionsGranted:
+		err = app.models.Permissions.AddForUser(user.ID, entry, input.Codes...)
+	default:
+		err = app.models.Permissions.RemoveForUser(user.ID, entry, input.Codes...)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.invalidateUser(user.ID)
+	app.writeUserAccess(w, r, user)
+}
+
+func (app *application) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"roles": roles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) grantUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRoles(w, r, data.AuditRolesGranted)
+}
+
+func (app *application) revokeUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUserRoles(w, r, data.AuditRolesRevoked)
+}
+
+func (app *application) changeUserRoles(w http.ResponseWriter, r *http.Request, action string) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	user, err := app.models.Users.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Roles []string `json:"roles"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	known, err := app.models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if data.ValidateRoleNames(v, input.Roles, known); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	entry := app.auditEntry(r, action, user.ID, map[string]interface{}{"roles": input.Roles})
+	switch action {
+	case data.AuditRolesGranted:
+		err = app.models.Roles.AddForUser(user.ID, entry, input.Roles...)
+	default:
+		err = app.models.Roles.RemoveForUser(user.ID, entry, input.Roles...)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.invalidateUser(user.ID)
+	app.writeUserAccess(w, r, user)
+}
+
+func (app *application) writeUserAccess(w http.ResponseWriter, r *http.Request, user *data.User) {
+	roles, err := app.models.Roles.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user, "roles": roles, "permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TargetUserID int
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.TargetUserID = app.readInt(qs, "target_user_id", 0, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-id"
+	input.Filters.SortSafelist = []string{"-id"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	entries, metadata, err := app.models.Audit.GetAll(int64(input.TargetUserID), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"audit_log": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// auditEntry describes an admin action taken by the current user, for the
+// model to record alongside the change.
+func (app *application) auditEntry(r *http.Request, action string, targetUserID int64, details map[string]interface{}) *data.AuditEntry {
+	return &data.AuditEntry{
+		ActorID:      app.contextGetUser(r).ID,
+		Action:       action,
+		TargetUserID: targetUserID,
+		Details:      details,
+	}
+}
+
+func (app *application) listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		Kind   string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Status = app.readString(qs, "status", "")
+	input.Kind = app.readString(qs, "kind", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-id"
+	input.Filters.SortSafelist = []string{"-id"}
+	data.ValidateJobFilters(v, input.Status)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	jobs, metadata, err := app.models.Jobs.GetAll(input.Status, input.Kind, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for _, job := range jobs {
+		job.Payload = redactSecrets(job.Payload)
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"jobs": jobs, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	job, err := app.models.Jobs.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	job.Payload = redactSecrets(job.Payload)
+	err = app.writeJSON(w, http.StatusOK, envelope{"job": job}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// retryJobHandler puts a dead job back in the queue with a fresh set of
+// attempts.
+func (app *application) retryJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	job, err := app.models.Jobs.Retry(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrJobNotRetryable):
+			app.jobNotRetryableResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	job.Payload = redactSecrets(job.Payload)
+	err = app.writeJSON(w, http.StatusOK, envelope{"job": job}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status    string
+		Recipient string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Status = app.readString(qs, "status", "")
+	input.Recipient = app.readString(qs, "recipient", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-id"
+	input.Filters.SortSafelist = []string{"-id"}
+	data.ValidateEmailFilters(v, input.Status)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	emails, metadata, err := app.models.Outbox.GetAll(input.Status, input.Recipient, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for _, email := range emails {
+		email.Data = redactSecrets(email.Data)
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"emails": emails, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showEmailHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	email, err := app.models.Outbox.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	email.Data = redactSecrets(email.Data)
+	err = app.writeJSON(w, http.StatusOK, envelope{"email": email}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// retryEmailHandler puts a failed email back in the outbox with a fresh set
+// of attempts.
+func (app *application) retryEmailHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	email, err := app.models.Outbox.Retry(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEmailNotRetryable):
+			app.emailNotRetryableResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	email.Data = redactSecrets(email.Data)
+	err = app.writeJSON(w, http.StatusOK, envelope{"email": email}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// redactSecrets replaces the values of fields that look like credentials,
+// such as activation tokens, in a JSON document shown to admins. Jobs and
+// emails carry whatever their handler needs, and admins debugging a queue
+// have no business seeing another user's token.
+func redactSecrets(js json.RawMessage) json.RawMessage {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return js
+	}
+	redacted, err := json.Marshal(redactValue(doc))
+	if err != nil {
+		return js
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if isSecretField(key) {
+				value[key] = "[redacted]"
+			} else {
+				value[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = redactValue(value[i])
+		}
+	}
+	return value
+}
+
+func isSecretField(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range []string{"token", "password", "secret"} {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	payload := json.RawMessage(`{"recipient":"a@example.com","data":{"activationToken":"ABC","userID":12345678901234567,"items":[{"reset_token":"DEF"}]},"Password":"x"}`)
+	got := string(redactSecrets(payload))
+	want := `{"Password":"[redacted]","data":{"activationToken":"[redacted]","items":[{"reset_token":"[redacted]"}],"userID":12345678901234567},"recipient":"a@example.com"}`
+	if got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+	if got := string(redactSecrets(json.RawMessage(`not json`))); got != "not json" {
+		t.Fatalf("invalid JSON changed to %s", got)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/cache"
+	"Puzzle.Ayan.net/internal/data"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// authCache holds users and permissions read while authenticating requests.
+// It is local to the process: invalidateUser only clears this replica, and
+// other replicas keep serving the old state until their entries expire, so
+// the TTL bounds how long a revoked token or permission stays usable.
+type authCache struct {
+	users       *cache.Cache[string, data.User]
+	permissions *cache.Cache[int64, data.Permissions]
+
+	// generation is bumped by every invalidation. A lookup only stores what
+	// it read if no invalidation happened in the meantime, so a read that
+	// raced a change cannot put the old state back.
+	mu         sync.Mutex
+	generation uint64
+}
+
+func newAuthCache(ttl time.Duration) *authCache {
+	return &authCache{
+		users:       cache.New[string, data.User](ttl),
+		permissions: cache.New[int64, data.Permissions](ttl),
+	}
+}
+
+// runSweeper removes expired entries every minute until stop is called.
+func (c *authCache) runSweeper() (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Minute):
+			}
+			c.users.Sweep()
+			c.permissions.Sweep()
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (c *authCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// store runs set unless the cache has been invalidated since generation.
+func (c *authCache) store(generation uint64, set func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		set()
+	}
+}
+
+func (c *authCache) stats() interface{} {
+	return map[string]cache.Stats{
+		"users":       c.users.Stats(),
+		"permissions": c.permissions.Stats(),
+	}
+}
+
+func (app *application) publishCacheMetrics() {
+	expvar.Publish("cache", expvar.Func(func() interface{} {
+		if app.authCache == nil {
+			return nil
+		}
+		return app.authCache.stats()
+	}))
+}
+
+func (app *application) getUserForToken(tokenPlaintext string) (*data.User, error) {
+	if app.authCache == nil {
+		return app.models.Users.GetForToken(data.ScopeAuthentication, tokenPlaintext)
+	}
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+	key := hex.EncodeToString(hash[:])
+	if user, found := app.authCache.users.Get(key); found {
+		return &user, nil
+	}
+	generation := app.authCache.currentGeneration()
+	user, err := app.models.Users.GetForToken(data.ScopeAuthentication, tokenPlaintext)
+	if err != nil {
+		return nil, err
+	}
+	app.authCache.store(generation, func() { app.authCache.users.Set(key, *user) })
+	return user, nil
+}
+
+func (app *application) getPermissionsForUser(userID int64) (data.Permissions, error) {
+	if app.authCache == nil {
+		return app.models.Permissions.GetAllForUser(userID)
+	}
+	if permissions, found := app.authCache.permissions.Get(userID); found {
+		return permissions, nil
+	}
+	generation := app.authCache.currentGeneration()
+	permissions, err := app.models.Permissions.GetAllForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	app.authCache.store(generation, func() { app.authCache.permissions.Set(userID, permissions) })
+	return permissions, nil
+}
+
+// invalidateUser drops everything cached for the user. It must be called
+// after every write to the user row, which covers activation, password and
+// email changes, and after their permissions or roles change, so that no
+// cached token keeps the old state alive for the rest of the TTL. Only this
+// process is cleared; see authCache.
+func (app *application) invalidateUser(userID int64) {
+	if app.authCache == nil {
+		return
+	}
+	app.authCache.mu.Lock()
+	defer app.authCache.mu.Unlock()
+	app.authCache.generation++
+	app.authCache.users.DeleteFunc(func(_ string, user data.User) bool {
+		return user.ID == userID
+	})
+	app.authCache.permissions.Delete(userID)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"testing"
+	"time"
+)
+
+func TestInvalidateUser(t *testing.T) {
+	app := &application{authCache: newAuthCache(time.Minute)}
+	app.authCache.users.Set("token-1", data.User{ID: 1})
+	app.authCache.users.Set("token-2", data.User{ID: 1})
+	app.authCache.users.Set("token-3", data.User{ID: 2})
+	app.authCache.permissions.Set(1, data.Permissions{"puzzles:read"})
+	app.authCache.permissions.Set(2, data.Permissions{"puzzles:read"})
+
+	app.invalidateUser(1)
+
+	for _, key := range []string{"token-1", "token-2"} {
+		if _, found := app.authCache.users.Get(key); found {
+			t.Fatalf("token %s of the changed user is still cached", key)
+		}
+	}
+	if _, found := app.authCache.permissions.Get(1); found {
+		t.Fatalf("permissions of the changed user are still cached")
+	}
+	if _, found := app.authCache.users.Get("token-3"); !found {
+		t.Fatalf("token of another user was dropped")
+	}
+	if _, found := app.authCache.permissions.Get(2); !found {
+		t.Fatalf("permissions of another user were dropped")
+	}
+}
+
+func TestInvalidationWinsOverInFlightLookup(t *testing.T) {
+	app := &application{authCache: newAuthCache(time.Minute)}
+	// A lookup reads the database, then the user changes before it stores
+	// what it read.
+	generation := app.authCache.currentGeneration()
+	app.invalidateUser(1)
+	app.authCache.store(generation, func() { app.authCache.users.Set("token-1", data.User{ID: 1}) })
+	app.authCache.store(generation, func() { app.authCache.permissions.Set(1, data.Permissions{"puzzles:read"}) })
+	if _, found := app.authCache.users.Get("token-1"); found {
+		t.Fatalf("a lookup that raced the invalidation cached the old user")
+	}
+	if _, found := app.authCache.permissions.Get(1); found {
+		t.Fatalf("a lookup that raced the invalidation cached the old permissions")
+	}
+
+	generation = app.authCache.currentGeneration()
+	app.authCache.store(generation, func() { app.authCache.users.Set("token-1", data.User{ID: 1}) })
+	if _, found := app.authCache.users.Get("token-1"); !found {
+		t.Fatalf("a lookup without a concurrent invalidation was not cached")
+	}
+}
+
+func TestSweeperStops(t *testing.T) {
+	c := newAuthCache(time.Minute)
+	stop := c.runSweeper()
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("the sweeper did not stop")
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/validator"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+)
+
+func (app *application) createCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Public      bool   `json:"public"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	collection := &data.Collection{
+		UserID:      app.contextGetUser(r).ID,
+		Name:        input.Name,
+		Description: input.Description,
+		Public:      input.Public,
+	}
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Collections.Insert(collection)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/collections/%d", collection.ID))
+	headers.Set("ETag", collectionETag(collection))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"collection": collection}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listOwnCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	var filters data.Filters
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-updated_at")
+	filters.SortSafelist = []string{"id", "name", "updated_at", "-id", "-name", "-updated_at"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	collections, metadata, err := app.models.Collections.GetAllForUser(app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"collections": collections, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection := app.readCollection(w, r, false)
+	if collection == nil {
+		return
+	}
+	if app.notModified(w, r, collectionETag(collection)) {
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection := app.readCollection(w, r, true)
+	if collection == nil {
+		return
+	}
+	if app.preconditionFailed(w, r, collectionETag(collection)) {
+		return
+	}
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Public      *bool   `json:"public"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Name != nil {
+		collection.Name = *input.Name
+	}
+	if input.Description != nil {
+		collection.Description = *input.Description
+	}
+	if input.Public != nil {
+		collection.Public = *input.Public
+	}
+	v := validator.New()
+	if data.ValidateCollection(v, collection); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Collections.Update(collection)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", collectionETag(collection))
+	err = app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection := app.readCollection(w, r, true)
+	if collection == nil {
+		return
+	}
+	if app.preconditionFailed(w, r, collectionETag(collection)) {
+		return
+	}
+	err := app.models.Collections.Delete(collection.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": app.translate(r, "message.collection_deleted")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listCollectionEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	collection := app.readCollection(w, r, false)
+	if collection == nil {
+		return
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	var filters data.Filters
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "position")
+	filters.SortSafelist = []string{"position", "added_at", "-position", "-added_at"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	entries, metadata, err := app.models.Collections.GetEntries(collection.ID, app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"entries": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// addCollectionEntryHandler adds a pack, or one item of it when item_id is
+// given, to the collection. Without a position the entry goes at the end.
+// Only packs the user can see may be added.
+func (app *application) addCollectionEntryHandler(w http.ResponseWriter, r *http.Request) {
+	collection := app.readCollection(w, r, true)
+	if collection == nil {
+		return
+	}
+	if app.preconditionFailed(w, r, collectionETag(collection)) {
+		return
+	}
+	var input struct {
+		PuzzleID int64  `json:"puzzle_id"`
+		ItemID   *int64 `json:"item_id"`
+		Position *int   `json:"position"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	entry := &data.CollectionEntry{PuzzleID: input.PuzzleID, ItemID: input.ItemID}
+	v := validator.New()
+	v.Check(input.PuzzleID > 0, "puzzle_id", "validation.positive")
+	v.Check(input.ItemID == nil || *input.ItemID > 0, "item_id", "validation.positive")
+	if input.Position != nil {
+		entry.Position = *input.Position
+		v.Check(entry.Position > 0, "position", "validation.greater_than_zero")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	puzzle, err := app.models.Puzzles.Get(entry.PuzzleID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	visible := false
+	if puzzle != nil {
+		visible, err = app.canViewPuzzle(r, puzzle)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	if v.Check(visible, "puzzle_id", "validation.not_found"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	entry.PuzzleTitle = puzzle.Title
+	if entry.ItemID != nil {
+		item, err := app.models.Items.Get(puzzle.ID, *entry.ItemID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("item_id", "validation.not_found")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		entry.ItemTitle = item.Title
+	}
+	err = app.models.Collections.AddEntry(collection, entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEntry):
+			v.AddError("puzzle_id", "validation.collection_entry_exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", collectionETag(collection))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"entry": entry}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) moveCollectionEntryHandler(w http.ResponseWriter, r *http.Request) {
+	collection := app.readCollection(w, r, true)
+	if collection == nil {
+		return
+	}
+	if app.preconditionFailed(w, r, collectionETag(collection)) {
+		return
+	}
+	entryID, err := app.readEntryParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	var input struct {
+		Position int `json:"position"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if v.Check(input.Position > 0, "position", "validation.greater_than_zero"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Collections.MoveEntry(collection, entryID, input.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", collectionETag(collection))
+	err = app.writeJSON(w, http.StatusOK, envelope{"collection": collection}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeCollectionEntryHandler(w http.ResponseWriter, r *http.Request) {
+	collection := app.readCollection(w, r, true)
+	if collection == nil {
+		return
+	}
+	if app.preconditionFailed(w, r, collectionETag(collection)) {
+		return
+	}
+	entryID, err := app.readEntryParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Collections.RemoveEntry(collection, entryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": app.translate(r, "message.collection_entry_removed")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readCollection loads the collection named by the :id parameter, which is
+// either its numeric id or its slug. Collections of other users, and every
+// collection for anonymous readers, are only visible when public and never
+// writable. A 404 or 403 is written and nil returned when the collection
+// cannot be used.
+func (app *application) readCollection(w http.ResponseWriter, r *http.Request, write bool) *data.Collection {
+	param := httprouter.ParamsFromContext(r.Context()).ByName("id")
+	var collection *data.Collection
+	var err error
+	if id, parseErr := strconv.ParseInt(param, 10, 64); parseErr == nil {
+		collection, err = app.models.Collections.Get(id)
+	} else {
+		collection, err = app.models.Collections.GetBySlug(param)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	if collection.UserID == app.contextGetUser(r).ID {
+		return collection
+	}
+	switch {
+	case !collection.Public:
+		app.notFoundResponse(w, r)
+		return nil
+	case write:
+		app.notPermittedResponse(w, r)
+		return nil
+	}
+	return collection
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/i18n"
+	"Puzzle.Ayan.net/internal/mailer"
+	"github.com/julienschmidt/httprouter"
+	"html/template"
+	"net/http"
+	"slices"
+)
+
+// devMailSamples holds the data each template is previewed with. Query
+// string parameters on the preview URL override single values, except for
+// locale which picks the translation.
+var devMailSamples = map[string]map[string]interface{}{
+	"user_welcome.tmpl": {
+		"activationToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+		"userID":          42,
+	},
+	"daily_reminder.tmpl": {
+		"name":           "Aigerim",
+		"puzzleTitle":    "Morning Crossword",
+		"puzzleURL":      "http://localhost:4000/v1/puzzles/7",
+		"unsubscribeURL": "http://localhost:4000/v1/notifications/unsubscribe?token=sample",
+	},
+	"weekly_digest.tmpl": {
+		"name":           "Aigerim",
+		"published":      12,
+		"ownCreated":     2,
+		"ownTotal":       9,
+		"ownPublished":   5,
+		"unsubscribeURL": "http://localhost:4000/v1/notifications/unsubscribe?token=sample",
+	},
+	"new_packs.tmpl": {
+		"name": "Aigerim",
+		"packs": []map[string]interface{}{
+			{"title": "Killer Sudoku Pack", "genres": "sudoku", "url": "http://localhost:4000/v1/puzzles/11"},
+			{"title": "Cryptic Weekend", "genres": "crossword, cryptic", "url": "http://localhost:4000/v1/puzzles/12"},
+		},
+		"unsubscribeURL": "http://localhost:4000/v1/notifications/unsubscribe?token=sample",
+	},
+}
+
+var devMailPage = template.Must(template.New("devmail").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.message { border-top: 1px solid #ccc; padding: 1em 0; }
+.meta { color: #555; font-size: 90%; }
+.parts { display: flex; gap: 1em; }
+.parts > * { flex: 1; min-width: 0; height: 30em; border: 1px solid #ccc; margin: 0; }
+pre { overflow: auto; padding: 0.5em; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>
+Preview a template:
+{{range .Templates}}<a href="/dev/mail/templates/{{.}}">{{.}}</a> {{end}}
+{{if .Preview}}| <a href="/dev/mail">Back to sent emails</a>{{end}}
+</p>
+{{if .Preview}}
+<p>
+Locale:
+{{range .Locales}}<a href="/dev/mail/templates/{{$.Template}}?locale={{.}}">{{.}}</a> {{end}}
+</p>
+{{end}}
+{{range .Messages}}
+<div class="message">
+<h2>{{.Subject}}</h2>
+<p class="meta">To {{.To}} from {{.From}} at {{.Date.Format "2006-01-02 15:04:05"}}</p>
+<div class="parts">
+<iframe sandbox srcdoc="{{.HTMLBody}}"></iframe>
+<pre>{{.PlainBody}}</pre>
+</div>
+</div>
+{{else}}
+<p>No emails have been sent since the server started.</p>
+{{end}}
+</body>
+</html>
+`))
+
+type devMailData struct {
+	Title     string
+	Templates []string
+	Messages  []*mailer.Message
+	Preview   bool
+	Template  string
+	Locales   []string
+}
+
+// devMailHandler lists the emails sent since the server started, newest
+// first. It is only routed with -dev-mail.
+func (app *application) devMailHandler(w http.ResponseWriter, r *http.Request) {
+	templates, err := mailer.Templates()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	messages := app.sentMail.Messages()
+	slices.Reverse(messages)
+	app.renderDevMail(w, r, devMailData{
+		Title:     "Sent emails",
+		Templates: templates,
+		Messages:  messages,
+	})
+}
+
+// devMailTemplateHandler renders a template with sample data without sending
+// it.
+func (app *application) devMailTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	templates, err := mailer.Templates()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+	if !slices.Contains(templates, name) {
+		app.notFoundResponse(w, r)
+		return
+	}
+	data := map[string]interface{}{}
+	for key, value := range devMailSamples[name] {
+		data[key] = value
+	}
+	locale := i18n.DefaultLocale
+	for key, values := range r.URL.Query() {
+		if key == "locale" {
+			locale = values[0]
+			continue
+		}
+		data[key] = values[0]
+	}
+	msg, err := app.mailer.Render(locale, "someone@example.com", name, data)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.renderDevMail(w, r, devMailData{
+		Title:     "Preview of " + name,
+		Templates: templates,
+		Messages:  []*mailer.Message{msg},
+		Preview:   true,
+		Template:  name,
+		Locales:   i18n.Locales,
+	})
+}
+
+func (app *application) renderDevMail(w http.ResponseWriter, r *http.Request, data devMailData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := devMailPage.Execute(w, data)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"Puzzle.Ayan.net/internal/validator"
 	"net/http"
 )
 
@@ -23,51 +23,69 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
-	message := "the server encountered a problem and could not process your request"
+	message := app.translate(r, "error.server")
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the requested resource could not be found"
+	message := app.translate(r, "error.not_found")
 	app.errorResponse(w, r, http.StatusNotFound, message)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
+	message := app.translate(r, "error.method_not_allowed", r.Method)
 	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
 }
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]validator.Error) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, app.translateErrors(r, errors))
 }
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
-	message := "unable to update the record due to an edit conflict, please try again"
+	message := app.translate(r, "error.edit_conflict")
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.translate(r, "error.precondition_failed")
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+func (app *application) invalidStatusTransitionResponse(w http.ResponseWriter, r *http.Request, from, to string) {
+	message := app.translate(r, "error.invalid_transition", from, to)
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
-	message := "rate limit exceeded"
+	message := app.translate(r, "error.rate_limit")
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
-	message := "invalid authentication credentials"
+	message := app.translate(r, "error.invalid_credentials")
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", "Bearer")
-	message := "invalid or missing authentication token"
+	message := app.translate(r, "error.invalid_token")
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
-	message := "you must be authenticated to access this resource"
+	message := app.translate(r, "error.authentication_required")
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
-	message := "your user account must be activated to access this resource"
+	message := app.translate(r, "error.inactive_account")
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
-	message := "your user account doesn't have the necessary permissions to access this resource"
+	message := app.translate(r, "error.not_permitted")
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) jobNotRetryableResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.translate(r, "error.job_not_retryable")
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) emailNotRetryableResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.translate(r, "error.email_not_retryable")
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// puzzleETag identifies one version of a puzzle. The version column is bumped
+// on every write, so the id and version pair is enough to tell versions apart.
+// It is the tag If-Match is checked against.
+func puzzleETag(puzzle *data.Puzzle) string {
+	return fmt.Sprintf(`"%d-%d"`, puzzle.ID, puzzle.Version)
+}
+
+// puzzleReadETag is the tag handed out with a puzzle. The thumbnail is set by
+// a background job and the rating follows the reviews, and both change
+// without a new version, so they are appended for the sake of If-None-Match;
+// puzzlePreconditionFailed ignores them again.
+func puzzleReadETag(puzzle *data.Puzzle) string {
+	tag := fmt.Sprintf(`"%d-%d`, puzzle.ID, puzzle.Version)
+	if puzzle.Thumbnail != "" {
+		sum := sha256.Sum256([]byte(puzzle.Thumbnail))
+		tag += "-t" + hex.EncodeToString(sum[:4])
+	}
+	if puzzle.RatingCount > 0 {
+		tag += fmt.Sprintf("-r%d-%.2f", puzzle.RatingCount, puzzle.Rating)
+	}
+	return tag + `"`
+}
+
+// reviewETag identifies one version of a review.
+func reviewETag(review *data.Review) string {
+	return fmt.Sprintf(`"r%d-%d"`, review.ID, review.Version)
+}
+
+// collectionETag identifies one version of a collection. Changes to its
+// entries bump the version too.
+func collectionETag(collection *data.Collection) string {
+	return fmt.Sprintf(`"c%d-%d"`, collection.ID, collection.Version)
+}
+
+// puzzleListETag identifies one page of a puzzle listing, covering both the
+// puzzles on the page and the pagination metadata.
+func puzzleListETag(puzzles []*data.Puzzle, metadata data.Metadata) string {
+	hash := sha256.New()
+	for _, puzzle := range puzzles {
+		fmt.Fprintf(hash, "%s,", puzzleReadETag(puzzle))
+	}
+	fmt.Fprintf(hash, "%d-%d-%d", metadata.CurrentPage, metadata.PageSize, metadata.TotalRecords)
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether etag is listed in the value of an If-Match or
+// If-None-Match header. Weak validators are compared by their opaque part
+// only, which is what If-None-Match requires and is harmless for If-Match
+// because we never issue weak tags ourselves.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header and, if the client already holds this
+// representation, answers with 304 Not Modified. It returns true when the
+// response has been written.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// preconditionFailed checks the If-Match header against the ETag of the
+// current version of a resource and writes a 412 response if it does not
+// match. Requests without If-Match are always allowed through.
+func (app *application) preconditionFailed(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagMatches(header, etag) {
+		return false
+	}
+	app.preconditionFailedResponse(w, r)
+	return true
+}
+
+// puzzleReadSuffix matches the thumbnail and rating puzzleReadETag appends to
+// a tag.
+var puzzleReadSuffix = regexp.MustCompile(`(-t[0-9a-f]{8})?(-r\d+-\d+\.\d+)?"`)
+
+// puzzlePreconditionFailed is preconditionFailed for puzzles. Only the
+// version part of the tags in If-Match is compared, so that somebody else
+// reviewing the puzzle, or its thumbnail being made, does not fail the
+// owner's next write.
+func (app *application) puzzlePreconditionFailed(w http.ResponseWriter, r *http.Request, puzzle *data.Puzzle) bool {
+	header := puzzleReadSuffix.ReplaceAllString(r.Header.Get("If-Match"), `"`)
+	if header == "" || etagMatches(header, puzzleETag(puzzle)) {
+		return false
+	}
+	app.preconditionFailedResponse(w, r)
+	return true
+}
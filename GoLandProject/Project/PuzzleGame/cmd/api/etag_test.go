@@ -0,0 +1,73 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPuzzleReadETag(t *testing.T) {
+	puzzle := &data.Puzzle{ID: 7, Version: 3}
+	if tag := puzzleReadETag(puzzle); tag != `"7-3"` {
+		t.Fatalf("unrated puzzle has tag %s", tag)
+	}
+	puzzle.Rating, puzzle.RatingCount = 4.5, 2
+	rated := puzzleReadETag(puzzle)
+	if rated != `"7-3-r2-4.50"` {
+		t.Fatalf("rated puzzle has tag %s", rated)
+	}
+	puzzle.Rating, puzzle.RatingCount = 4, 3
+	if puzzleReadETag(puzzle) == rated {
+		t.Fatalf("a new review left the read tag unchanged")
+	}
+	if tag := puzzleETag(puzzle); tag != `"7-3"` {
+		t.Fatalf("If-Match tag %s includes the rating", tag)
+	}
+
+	before := puzzleReadETag(puzzle)
+	puzzle.Thumbnail = "thumbnails/items/12.png"
+	withThumbnail := puzzleReadETag(puzzle)
+	if withThumbnail == before {
+		t.Fatalf("a new thumbnail left the read tag unchanged")
+	}
+	if !puzzleReadSuffix.MatchString(withThumbnail) || puzzleReadSuffix.ReplaceAllString(withThumbnail, `"`) != `"7-3"` {
+		t.Fatalf("read tag %s does not reduce to the version", withThumbnail)
+	}
+}
+
+func TestPuzzlePreconditionFailed(t *testing.T) {
+	app := &application{}
+	puzzle := &data.Puzzle{ID: 7, Version: 3, Rating: 4, RatingCount: 3}
+	tests := []struct {
+		ifMatch string
+		failed  bool
+	}{
+		{"", false},
+		{"*", false},
+		{`"7-3"`, false},
+		// A tag read before somebody else reviewed the puzzle.
+		{`"7-3-r2-4.50"`, false},
+		{`"8-1", "7-3-r1-5.00"`, false},
+		{`"7-2"`, true},
+		{`"7-2-r2-4.50"`, true},
+		// A tag read before the thumbnail was made, and one read after.
+		{`"7-3-r3-4.00"`, false},
+		{`"7-3-t0a1b2c3d-r3-4.00"`, false},
+		{`"7-2-t0a1b2c3d"`, true},
+		{`"17-3"`, true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPatch, "/v1/puzzles/7", nil)
+		if tt.ifMatch != "" {
+			req.Header.Set("If-Match", tt.ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		if failed := app.puzzlePreconditionFailed(rr, req, puzzle); failed != tt.failed {
+			t.Fatalf("If-Match %s: got %v, want %v", tt.ifMatch, failed, tt.failed)
+		}
+		if tt.failed && rr.Code != http.StatusPreconditionFailed {
+			t.Fatalf("If-Match %s: got status %d, want 412", tt.ifMatch, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/validator"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var exportFormats = map[string]string{
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+	"csv":    "text/csv",
+}
+
+// puzzleEncoder writes a stream of puzzles in one export format. begin and
+// end frame the output, and encode is called once per puzzle in between.
+type puzzleEncoder interface {
+	begin() error
+	encode(puzzle *data.Puzzle) error
+	end() error
+}
+
+func (app *application) exportPuzzlesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	input := app.readPuzzleListInput(qs, data.StatusPublished, v)
+	format := app.readString(qs, "format", "json")
+	v.Check(exportFormats[format] != "", "format", "validation.one_of", "json, ndjson, csv")
+	moderator, err := app.userHasPermission(r, "puzzles:moderate")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !moderator {
+		v.Check(input.Status == data.StatusPublished, "status", "validation.published_only")
+	}
+	v.Check(validator.In(input.Filters.Sort, input.Filters.SortSafelist...), "sort", "validation.sort")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// An export can take far longer than the server-wide write timeout, so
+	// the deadline is pushed out for this response only.
+	rc := http.NewResponseController(w)
+	err = rc.SetWriteDeadline(time.Now().Add(app.config.exports.timeout))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.exports.timeout)
+	defer cancel()
+
+	buf := bufio.NewWriterSize(w, 32*1024)
+	var enc puzzleEncoder
+	switch format {
+	case "json":
+		enc = &jsonPuzzleEncoder{w: buf}
+	case "ndjson":
+		enc = &ndjsonPuzzleEncoder{w: buf}
+	case "csv":
+		enc = &csvPuzzleEncoder{w: csv.NewWriter(buf)}
+	}
+
+	// Headers are only sent once the first row arrives, so that a failing
+	// query can still be reported as a normal error response.
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", exportFormats[format])
+		w.Header().Set("Content-Disposition", `attachment; filename="puzzles.`+format+`"`)
+		w.WriteHeader(http.StatusOK)
+		return enc.begin()
+	}
+	count := 0
+	err = app.models.Puzzles.Stream(ctx, input.Title, input.Genres, input.Status, input.MinRating, input.Filters, func(puzzle *data.Puzzle) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := enc.encode(puzzle); err != nil {
+			return err
+		}
+		count++
+		if count%500 == 0 {
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		// The status line has already gone out, so all we can do is cut
+		// the stream short and leave a trace in the logs.
+		app.logError(r, err)
+		return
+	}
+	if !started {
+		err = start()
+	}
+	if err == nil {
+		err = enc.end()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+type jsonPuzzleEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonPuzzleEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonPuzzleEncoder) encode(puzzle *data.Puzzle) error {
+	js, err := json.Marshal(puzzle)
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if e.count == 0 {
+		separator = "\n"
+	}
+	e.count++
+	_, err = io.WriteString(e.w, separator)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(js)
+	return err
+}
+
+func (e *jsonPuzzleEncoder) end() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+type ndjsonPuzzleEncoder struct {
+	w io.Writer
+}
+
+func (e *ndjsonPuzzleEncoder) begin() error {
+	return nil
+}
+
+func (e *ndjsonPuzzleEncoder) encode(puzzle *data.Puzzle) error {
+	js, err := json.Marshal(puzzle)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(js, '\n'))
+	return err
+}
+
+func (e *ndjsonPuzzleEncoder) end() error {
+	return nil
+}
+
+// csvPuzzleEncoder uses the same column layout as the CSV import, with the
+// genres of a puzzle joined by "|", so an export can be imported again.
+type csvPuzzleEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvPuzzleEncoder) begin() error {
+	return e.w.Write([]string{"id", "created_at", "title", "num_of_puzzles", "genres", "status", "version"})
+}
+
+func (e *csvPuzzleEncoder) encode(puzzle *data.Puzzle) error {
+	err := e.w.Write([]string{
+		strconv.FormatInt(puzzle.ID, 10),
+		puzzle.CreatedAt.Format(time.RFC3339),
+		puzzle.Title,
+		strconv.FormatInt(int64(puzzle.NumOfPuzzles), 10),
+		strings.Join(puzzle.Genres, "|"),
+		puzzle.Status,
+		strconv.FormatInt(int64(puzzle.Version), 10),
+	})
+	if err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvPuzzleEncoder) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/validator"
+	"errors"
+	"net/http"
+)
+
+// addFavoriteHandler marks a puzzle as a favorite of the current user. It is
+// a PUT, so favoriting a puzzle twice is not an error.
+func (app *application) addFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	puzzle := app.readViewablePuzzle(w, r)
+	if puzzle == nil {
+		return
+	}
+	favorite, err := app.models.Favorites.Add(app.contextGetUser(r).ID, puzzle.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"favorite": favorite}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// removeFavoriteHandler does not check that the puzzle is still visible, so
+// that a pack which has since been unpublished can still be dropped.
+func (app *application) removeFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	err = app.models.Favorites.Remove(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": app.translate(r, "message.favorite_removed")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listFavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	var filters data.Filters
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-favorited_at")
+	filters.SortSafelist = []string{"favorited_at", "title", "-favorited_at", "-title"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	puzzles, metadata, err := app.models.Favorites.GetAll(app.contextGetUser(r).ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"puzzles": puzzles, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
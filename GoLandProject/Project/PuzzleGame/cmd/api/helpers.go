@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -22,6 +23,42 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+func (app *application) readVersionParam(r *http.Request) (int32, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	version, err := strconv.ParseInt(params.ByName("version"), 10, 32)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid version parameter")
+	}
+	return int32(version), nil
+}
+
+func (app *application) readReviewParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("review"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid review parameter")
+	}
+	return id, nil
+}
+
+func (app *application) readEntryParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("entry"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid entry parameter")
+	}
+	return id, nil
+}
+
+func (app *application) readItemParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("item"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid item parameter")
+	}
+	return id, nil
+}
+
 type envelope map[string]interface{}
 
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
@@ -99,11 +136,35 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	}
 	i, err := strconv.Atoi(s)
 	if err != nil {
-		v.AddError(key, "must be an integer value")
+		v.AddError(key, "validation.integer")
 		return defaultValue
 	}
 	return i
 }
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		v.AddError(key, "validation.number")
+		return defaultValue
+	}
+	return f
+}
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "validation.boolean")
+		return defaultValue
+	}
+	return b
+}
 
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
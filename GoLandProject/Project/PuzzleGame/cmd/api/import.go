@@ -0,0 +1,358 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/i18n"
+	"Puzzle.Ayan.net/internal/validator"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const maxImportErrors = 1000
+
+var importFormats = []string{"json", "ndjson", "csv"}
+
+type importRecord struct {
+	Title        string   `json:"title"`
+	NumOfPuzzles data.NOP `json:"num_of_puzzles"`
+	Genres       []string `json:"genres"`
+}
+
+type importRowError struct {
+	Row    int               `json:"row"`
+	Errors map[string]string `json:"errors"`
+}
+
+// recordReader yields import records one at a time so that the raw upload
+// never has to be held in memory as a whole. Next returns io.EOF after the last
+// record. A *rowError is reported for a single malformed record and reading
+// may continue after it; any other error aborts the import.
+type recordReader interface {
+	Next() (*importRecord, error)
+}
+
+// rowError holds a message key like a failed validation check, so that it is
+// translated along with the rest of the report.
+type rowError struct {
+	field string
+	err   validator.Error
+}
+
+func newRowError(field, key string, args ...interface{}) *rowError {
+	return &rowError{field: field, err: validator.Error{Key: key, Args: args}}
+}
+
+func (e *rowError) Error() string {
+	return e.field + ": " + i18n.T(i18n.DefaultLocale, e.err.Key, e.err.Args...)
+}
+
+func (app *application) importPuzzlesHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.imports.maxBytes)
+	body, format, err := app.readImportBody(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	if v.Check(validator.In(format, importFormats...), "format", "validation.one_of", "json, ndjson, csv"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	var records recordReader
+	switch format {
+	case "json":
+		records, err = newJSONRecordReader(body)
+	case "ndjson":
+		records = newNDJSONRecordReader(body)
+	case "csv":
+		records, err = newCSVRecordReader(body)
+	}
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	genres, err := app.models.Genres.Set()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// The whole body is read and validated before the batch is opened, so
+	// that no transaction is held open while the client is still sending.
+	ownerID := app.contextGetUser(r).ID
+	report := []importRowError{}
+	var puzzles []*data.Puzzle
+	for row := 1; len(report) < maxImportErrors; row++ {
+		record, err := records.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var malformed *rowError
+		switch {
+		case errors.As(err, &malformed):
+			report = append(report, importRowError{Row: row, Errors: app.translateErrors(r, map[string]validator.Error{malformed.field: malformed.err})})
+			continue
+		case err != nil:
+			app.badRequestResponse(w, r, fmt.Errorf("row %d: %w", row, app.importReadError(err)))
+			return
+		}
+		puzzle := &data.Puzzle{
+			Title:        record.Title,
+			NumOfPuzzles: record.NumOfPuzzles,
+			Genres:       record.Genres,
+			OwnerID:      ownerID,
+		}
+		v := validator.New()
+		puzzle.Genres = data.ResolveGenres(v, "genres", puzzle.Genres, genres)
+		if data.ValidateMovie(v, puzzle); !v.Valid() {
+			report = append(report, importRowError{Row: row, Errors: app.translateErrors(r, v.Errors)})
+			continue
+		}
+		// Once a row has failed nothing is going to be imported, so the
+		// remaining rows are only validated.
+		if len(report) == 0 {
+			puzzles = append(puzzles, puzzle)
+		}
+	}
+	if len(report) > 0 {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, envelope{
+			"message": app.translate(r, "error.import_rows_invalid"),
+			"rows":    report,
+		})
+		return
+	}
+
+	batch, err := app.models.Puzzles.BeginBatch(app.config.imports.timeout)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer batch.Rollback()
+	for _, puzzle := range puzzles {
+		err = batch.Insert(puzzle)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = batch.Commit()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusCreated, envelope{"imported": len(puzzles)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readImportBody returns the stream holding the records and the format they
+// are in. Multipart uploads are read part by part without buffering the form,
+// and the format is taken from the format query parameter, the file name or
+// the content type, in that order.
+func (app *application) readImportBody(r *http.Request) (io.Reader, string, error) {
+	format := r.URL.Query().Get("format")
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if format == "" {
+			format = importFormatFor(mediaType, "")
+		}
+		return r.Body, format, nil
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, "", errors.New("multipart body must contain a file field")
+		}
+		if err != nil {
+			return nil, "", app.importReadError(err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		if format == "" {
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			format = importFormatFor(partType, part.FileName())
+		}
+		return part, format, nil
+	}
+}
+
+func importFormatFor(mediaType, filename string) string {
+	switch strings.TrimPrefix(filepath.Ext(filename), ".") {
+	case "json":
+		return "json"
+	case "ndjson", "jsonl":
+		return "ndjson"
+	case "csv":
+		return "csv"
+	}
+	switch mediaType {
+	case "application/json":
+		return "json"
+	case "application/x-ndjson", "application/jsonl":
+		return "ndjson"
+	case "text/csv":
+		return "csv"
+	}
+	return ""
+}
+
+func (app *application) importReadError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return fmt.Errorf("body must not be larger than %d bytes", app.config.imports.maxBytes)
+	}
+	return err
+}
+
+type jsonRecordReader struct {
+	dec *json.Decoder
+}
+
+// newJSONRecordReader reads a JSON array element by element. Because the
+// array is one value, a syntax error anywhere in it aborts the import, but a
+// well-formed element with the wrong shape is reported against its row.
+func newJSONRecordReader(r io.Reader) (*jsonRecordReader, error) {
+	dec := json.NewDecoder(r)
+	token, err := dec.Token()
+	if err != nil {
+		return nil, errors.New("body must contain a JSON array")
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("body must contain a JSON array")
+	}
+	return &jsonRecordReader{dec: dec}, nil
+}
+
+func (jr *jsonRecordReader) Next() (*importRecord, error) {
+	if !jr.dec.More() {
+		_, err := jr.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	var raw json.RawMessage
+	err := jr.dec.Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONRecord(raw)
+}
+
+type ndjsonRecordReader struct {
+	r *bufio.Reader
+}
+
+func newNDJSONRecordReader(r io.Reader) *ndjsonRecordReader {
+	return &ndjsonRecordReader{r: bufio.NewReader(r)}
+}
+
+func (nr *ndjsonRecordReader) Next() (*importRecord, error) {
+	for {
+		line, err := nr.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return decodeJSONRecord(line)
+	}
+}
+
+func decodeJSONRecord(raw []byte) (*importRecord, error) {
+	var record importRecord
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&record)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field != "":
+			return nil, newRowError(unmarshalTypeError.Field, "validation.json_type")
+		case errors.Is(err, data.ErrInvalidRuntimeFormat):
+			return nil, newRowError("num_of_puzzles", "validation.num_of_puzzles_format")
+		default:
+			return nil, newRowError("record", "validation.invalid", err)
+		}
+	}
+	return &record, nil
+}
+
+type csvRecordReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+// newCSVRecordReader expects a header row naming the title, num_of_puzzles
+// and genres columns in any order. Genres are separated by "|" within their
+// cell and num_of_puzzles is a plain integer.
+func newCSVRecordReader(r io.Reader) (*csvRecordReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("body must start with a CSV header row")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"title", "num_of_puzzles", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header must contain a %q column", name)
+		}
+	}
+	return &csvRecordReader{r: cr, columns: columns}, nil
+}
+
+func (cr *csvRecordReader) Next() (*importRecord, error) {
+	fields, err := cr.r.Read()
+	if err != nil {
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			return nil, newRowError("record", "validation.invalid", parseError.Err)
+		}
+		return nil, err
+	}
+	field := func(name string) string {
+		if i := cr.columns[name]; i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	record := &importRecord{Title: field("title")}
+	if s := field("num_of_puzzles"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return nil, newRowError("num_of_puzzles", "validation.integer")
+		}
+		record.NumOfPuzzles = data.NOP(n)
+	}
+	if s := field("genres"); s != "" {
+		for _, genre := range strings.Split(s, "|") {
+			record.Genres = append(record.Genres, strings.TrimSpace(genre))
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/formats"
+	"Puzzle.Ayan.net/internal/validator"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+var uploadFormats = []string{"puz", "ipuz", "sudoku"}
+
+// uploadPuzzleHandler creates a pack from a single puzzle file. The title and
+// genres of the pack may be given as query parameters and otherwise come from
+// the file itself and the kind of puzzles it holds.
+func (app *application) uploadPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.imports.maxBytes)
+	qs := r.URL.Query()
+	body, filename, err := app.readUploadFile(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	v := validator.New()
+	format := app.readString(qs, "format", uploadFormatFor(filename))
+	if v.Check(validator.In(format, uploadFormats...), "format", "validation.one_of", "puz, ipuz, sudoku"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var parsed []*formats.Item
+	switch format {
+	case "puz":
+		var item *formats.Item
+		item, err = formats.ParsePuz(body)
+		parsed = []*formats.Item{item}
+	case "ipuz":
+		var item *formats.Item
+		item, err = formats.ParseIPUZ(body)
+		parsed = []*formats.Item{item}
+	case "sudoku":
+		parsed, err = formats.ParseSudokuStrings(body)
+	}
+	if err != nil {
+		v.AddError("file", "validation.unreadable", err)
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	puzzle := &data.Puzzle{
+		Title:        app.readString(qs, "title", parsed[0].Title),
+		NumOfPuzzles: data.NOP(len(parsed)),
+		Genres:       app.readCSV(qs, "genres", []string{parsed[0].Kind}),
+		OwnerID:      app.contextGetUser(r).ID,
+	}
+	puzzle.Genres, err = app.resolveGenres(v, "genres", puzzle.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if data.ValidateMovie(v, puzzle); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	batch, err := app.models.Puzzles.BeginBatch(app.config.imports.timeout)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer batch.Rollback()
+	err = batch.Insert(puzzle)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	items := make([]*data.PuzzleItem, len(parsed))
+	for i, item := range parsed {
+		content, err := json.Marshal(item.Content)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		items[i] = &data.PuzzleItem{
+			PuzzleID: puzzle.ID,
+			Position: i + 1,
+			Kind:     item.Kind,
+			Title:    item.Title,
+			Content:  content,
+		}
+		err = batch.InsertItem(items[i])
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		err = app.queueThumbnail(batch, items[i])
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = batch.Commit()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/puzzles/%d", puzzle.ID))
+	headers.Set("ETag", puzzleReadETag(puzzle))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"puzzle": puzzle, "items": len(items)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readUploadFile returns the uploaded file and its name. The file is either
+// the whole body or the file field of a multipart form. Unlike bulk imports
+// the parsers need the complete file, so it is read into memory, bounded by
+// the import size limit.
+func (app *application) readUploadFile(r *http.Request) ([]byte, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, "", app.importReadError(err)
+		}
+		return b, "", nil
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, "", errors.New("multipart body must contain a file field")
+		}
+		if err != nil {
+			return nil, "", app.importReadError(err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		b, err := io.ReadAll(part)
+		if err != nil {
+			return nil, "", app.importReadError(err)
+		}
+		return b, part.FileName(), nil
+	}
+}
+
+func uploadFormatFor(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".puz":
+		return "puz"
+	case ".ipuz":
+		return "ipuz"
+	case ".txt", ".sdk":
+		return "sudoku"
+	}
+	return ""
+}
+
+func (app *application) listPuzzleItemsHandler(w http.ResponseWriter, r *http.Request) {
+	puzzle := app.readViewablePuzzle(w, r)
+	if puzzle == nil {
+		return
+	}
+	items, err := app.models.Items.GetAll(puzzle.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"items": items}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPuzzleItemHandler(w http.ResponseWriter, r *http.Request) {
+	item := app.readViewableItem(w, r)
+	if item == nil {
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"item": item}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readViewablePuzzle loads the puzzle named by the :id parameter and writes a
+// 404 when it does not exist or the user may not see it, in which case nil is
+// returned.
+func (app *application) readViewablePuzzle(w http.ResponseWriter, r *http.Request) *data.Puzzle {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+	puzzle, err := app.models.Puzzles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	visible, err := app.canViewPuzzle(r, puzzle)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil
+	}
+	if !visible {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+	return puzzle
+}
+
+func (app *application) readViewableItem(w http.ResponseWriter, r *http.Request) *data.PuzzleItem {
+	puzzle := app.readViewablePuzzle(w, r)
+	if puzzle == nil {
+		return nil
+	}
+	itemID, err := app.readItemParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+	item, err := app.models.Items.Get(puzzle.ID, itemID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	return item
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/formats"
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultJigsawPieces = 100
+	maxJigsawPixels     = 50_000_000
+)
+
+// jigsawImageTypes maps the image types accepted for jigsaws to the file
+// extension they are stored with. Only types the standard library can decode
+// are listed, since the image size has to be read to cut the pieces.
+var jigsawImageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+// createJigsawHandler creates a pack holding a single jigsaw from an uploaded
+// image. It takes a multipart form rather than JSON because images are far
+// larger than readJSON allows; the image goes in the "image" field and the
+// title, genres and number of pieces in form fields of the same names.
+func (app *application) createJigsawHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.uploads.maxImageBytes)
+	err := r.ParseMultipartForm(8 << 20)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			err = fmt.Errorf("body must not be larger than %d bytes", app.config.uploads.maxImageBytes)
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	v := validator.New()
+	form := url.Values(r.MultipartForm.Value)
+	pieces := app.readInt(form, "pieces", defaultJigsawPieces, v)
+	puzzle := &data.Puzzle{
+		Title:        app.readString(form, "title", ""),
+		NumOfPuzzles: 1,
+		Genres:       app.readCSV(form, "genres", []string{formats.KindJigsaw}),
+		OwnerID:      app.contextGetUser(r).ID,
+	}
+	puzzle.Genres, err = app.resolveGenres(v, "genres", puzzle.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	data.ValidateMovie(v, puzzle)
+	v.Check(pieces >= formats.MinJigsawPieces && pieces <= formats.MaxJigsawPieces, "pieces",
+		"validation.between", formats.MinJigsawPieces, formats.MaxJigsawPieces)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		v.AddError("image", "validation.required")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	defer file.Close()
+
+	// The declared content type is not trusted; the type is sniffed from the
+	// file itself and the image header decoded to get its size.
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := jigsawImageTypes[contentType]
+	if !ok {
+		v.AddError("image", "validation.image_type")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	var imageConfig image.Config
+	_, err = file.Seek(0, io.SeekStart)
+	if err == nil {
+		imageConfig, _, err = image.DecodeConfig(file)
+	}
+	if err != nil {
+		v.AddError("image", "validation.image_decode")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	v.Check(imageConfig.Width*imageConfig.Height <= maxJigsawPixels, "image", "validation.image_pixels", maxJigsawPixels/1_000_000)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	jigsaw, err := formats.CutJigsaw(imageConfig.Width, imageConfig.Height, pieces, time.Now().UnixNano())
+	if err != nil {
+		v.AddError("pieces", "validation.invalid", err)
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	key, err := newBlobKey("jigsaw", ext)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	jigsaw.Image = key
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.blobs.Put(r.Context(), key, file, contentType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.insertJigsaw(puzzle, jigsaw)
+	if err != nil {
+		if err := app.blobs.Delete(context.Background(), key); err != nil {
+			app.logError(r, err)
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/puzzles/%d", puzzle.ID))
+	headers.Set("ETag", puzzleReadETag(puzzle))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"puzzle": puzzle, "jigsaw": jigsaw}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) insertJigsaw(puzzle *data.Puzzle, jigsaw *formats.Jigsaw) error {
+	content, err := json.Marshal(jigsaw)
+	if err != nil {
+		return err
+	}
+	batch, err := app.models.Puzzles.BeginBatch(app.config.imports.timeout)
+	if err != nil {
+		return err
+	}
+	defer batch.Rollback()
+	err = batch.Insert(puzzle)
+	if err != nil {
+		return err
+	}
+	item := &data.PuzzleItem{
+		PuzzleID: puzzle.ID,
+		Position: 1,
+		Kind:     formats.KindJigsaw,
+		Title:    puzzle.Title,
+		Content:  content,
+	}
+	err = batch.InsertItem(item)
+	if err != nil {
+		return err
+	}
+	err = app.queueThumbnail(batch, item)
+	if err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// showItemImageHandler streams the image of a jigsaw item from blob storage.
+func (app *application) showItemImageHandler(w http.ResponseWriter, r *http.Request) {
+	stored := app.readViewableItem(w, r)
+	if stored == nil {
+		return
+	}
+	if stored.Kind != formats.KindJigsaw {
+		app.notFoundResponse(w, r)
+		return
+	}
+	item, err := formats.DecodeItem(stored.Kind, stored.Title, stored.Content)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.serveBlob(w, r, item.Content.(*formats.Jigsaw).Image)
+}
+
+// newBlobKey returns a random key under prefix. Keys are never derived from
+// user input such as the uploaded file name.
+func newBlobKey(prefix, ext string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return prefix + "/" + hex.EncodeToString(b) + ext, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/i18n"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	jobSendEmail = "send_email"
+	jobThumbnail = "thumbnail"
+)
+
+// Failed jobs are retried after jobRetryBase, doubling with every attempt up
+// to jobRetryMax.
+const (
+	jobRetryBase = 30 * time.Second
+	jobRetryMax  = time.Hour
+)
+
+// jobHandler runs one kind of job. Errors are retried with backoff unless
+// they are wrapped in permanentError.
+type jobHandler func(ctx context.Context, payload json.RawMessage) error
+
+// permanentError marks a failure that retrying cannot fix, such as a payload
+// that does not decode. The job is marked dead straight away.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// typedJob adapts a handler taking a decoded payload. Numbers in untyped
+// parts of the payload are kept as json.Number so that ids survive intact.
+func typedJob[T any](fn func(ctx context.Context, payload T) error) jobHandler {
+	return func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		err := dec.Decode(&payload)
+		if err != nil {
+			return permanentError{err: fmt.Errorf("invalid payload: %w", err)}
+		}
+		return fn(ctx, payload)
+	}
+}
+
+type sendEmailPayload struct {
+	Recipient string                 `json:"recipient"`
+	Template  string                 `json:"template"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+type thumbnailPayload struct {
+	PuzzleID int64 `json:"puzzle_id"`
+	ItemID   int64 `json:"item_id"`
+}
+
+func (app *application) jobHandlers() map[string]jobHandler {
+	return map[string]jobHandler{
+		// Emails now go through the outbox. Jobs queued before it existed
+		// are moved there rather than sent from here.
+		jobSendEmail: typedJob(func(ctx context.Context, payload sendEmailPayload) error {
+			return app.models.Outbox.Insert(payload.Recipient, i18n.DefaultLocale, payload.Template, payload.Data)
+		}),
+		jobThumbnail: typedJob(func(ctx context.Context, payload thumbnailPayload) error {
+			item, err := app.models.Items.Get(payload.PuzzleID, payload.ItemID)
+			if err != nil {
+				// The pack was deleted before its preview was made.
+				if errors.Is(err, data.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+			return app.generateThumbnail(ctx, item)
+		}),
+	}
+}
+
+// runJobs starts the job workers and returns a function that stops them,
+// waiting for the jobs they are running to finish.
+func (app *application) runJobs() (stop func()) {
+	handlers := app.jobHandlers()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < app.config.jobs.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// Keep going while there is work and only sleep once the
+				// queue is empty.
+				wait := app.config.jobs.pollInterval
+				if app.runNextJob(handlers) {
+					wait = 0
+				}
+				select {
+				case <-done:
+					return
+				case <-time.After(wait):
+				}
+			}
+		}()
+	}
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// runNextJob claims and runs one job. It returns false when there was no job
+// to run or the queue could not be read.
+func (app *application) runNextJob(handlers map[string]jobHandler) bool {
+	job, err := app.models.Jobs.Claim()
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.PrintError(err, nil)
+		}
+		return false
+	}
+	properties := map[string]string{
+		"job":  strconv.FormatInt(job.ID, 10),
+		"kind": job.Kind,
+	}
+	err = app.runJob(handlers, job)
+	switch {
+	case err == nil:
+		err = app.models.Jobs.Complete(job)
+	case errors.As(err, new(permanentError)):
+		app.logger.PrintError(err, properties)
+		err = app.models.Jobs.Kill(job, err)
+	default:
+		app.logger.PrintError(err, properties)
+		err = app.models.Jobs.Fail(job, err, time.Now().Add(retryDelay(job.Attempts)))
+		if err == nil && job.Status == data.JobDead {
+			app.logger.PrintInfo("job is dead after its last attempt", properties)
+		}
+	}
+	if err != nil {
+		app.logger.PrintError(err, properties)
+	}
+	return true
+}
+
+func (app *application) runJob(handlers map[string]jobHandler, job *data.Job) (err error) {
+	handler, ok := handlers[job.Kind]
+	if !ok {
+		return permanentError{err: fmt.Errorf("no handler for job kind %q", job.Kind)}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.jobs.timeout)
+	defer cancel()
+	return handler(ctx, job.Payload)
+}
+
+// retryDelay doubles the wait with every attempt and adds up to 10% jitter so
+// that jobs failing together do not all retry at the same moment.
+func retryDelay(attempts int) time.Duration {
+	delay := jobRetryMax
+	if attempts < 20 {
+		delay = min(jobRetryBase<<(attempts-1), jobRetryMax)
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/10)+1))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/i18n"
+	"Puzzle.Ayan.net/internal/validator"
+	"net/http"
+)
+
+// requestLocale picks the language of a response: the client's preference in
+// Accept-Language when it names a supported locale, then the locale the
+// authenticated user registered with, then the default.
+func (app *application) requestLocale(r *http.Request) string {
+	locale := i18n.Negotiate(r.Header.Get("Accept-Language"))
+	if locale != "" {
+		return locale
+	}
+	// Errors can be written before authenticate has stored a user.
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if ok && i18n.Supported(user.Locale) {
+		return user.Locale
+	}
+	return i18n.DefaultLocale
+}
+
+func (app *application) translate(r *http.Request, key string, args ...interface{}) string {
+	return i18n.T(app.requestLocale(r), key, args...)
+}
+
+func (app *application) translateErrors(r *http.Request, errors map[string]validator.Error) map[string]string {
+	locale := app.requestLocale(r)
+	messages := make(map[string]string, len(errors))
+	for field, e := range errors {
+		messages[field] = i18n.T(locale, e.Key, e.Args...)
+	}
+	return messages
+}
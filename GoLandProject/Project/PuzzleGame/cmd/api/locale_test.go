@@ -0,0 +1,65 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/i18n"
+	"Puzzle.Ayan.net/internal/validator"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponsesVaryByLanguage(t *testing.T) {
+	app := &application{}
+	req := httptest.NewRequest(http.MethodGet, "/v1/no-such-route", nil)
+	req.Header.Set("Accept-Language", "ru-RU, en;q=0.5")
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("got status %d, want 404", rr.Code)
+	}
+	if vary := strings.Join(rr.Header().Values("Vary"), ", "); !strings.Contains(vary, "Accept-Language") {
+		t.Fatalf("Vary is %q", vary)
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if want := i18n.T(i18n.Russian, "error.not_found"); body.Error != want {
+		t.Fatalf("got %q, want %q", body.Error, want)
+	}
+}
+
+func TestImportRowErrorsAreTranslated(t *testing.T) {
+	app := &application{}
+	req := httptest.NewRequest(http.MethodPost, "/v1/puzzles/import", nil)
+	req.Header.Set("Accept-Language", "kk")
+
+	_, err := decodeJSONRecord([]byte(`{"title": 5}`))
+	malformed, ok := err.(*rowError)
+	if !ok {
+		t.Fatalf("expected a *rowError, got %v", err)
+	}
+	messages := app.translateErrors(req, map[string]validator.Error{malformed.field: malformed.err})
+	if want := i18n.T(i18n.Kazakh, "validation.json_type"); messages["title"] != want {
+		t.Fatalf("got %q, want %q", messages["title"], want)
+	}
+
+	records, err := newCSVRecordReader(strings.NewReader("title,num_of_puzzles,genres\nPack,many,logic\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = records.Next()
+	malformed, ok = err.(*rowError)
+	if !ok {
+		t.Fatalf("expected a *rowError, got %v", err)
+	}
+	messages = app.translateErrors(req, map[string]validator.Error{malformed.field: malformed.err})
+	if want := i18n.T(i18n.Kazakh, "validation.integer"); messages["num_of_puzzles"] != want {
+		t.Fatalf("got %q, want %q", messages["num_of_puzzles"], want)
+	}
+}
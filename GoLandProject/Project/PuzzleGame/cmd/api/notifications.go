@@ -0,0 +1,277 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/validator"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// notificationPeriods is how often each notification is sent. A subscriber is
+// due a little early so that the hourly run does not drift later every time.
+var notificationPeriods = map[string]time.Duration{
+	data.NotifyDailyReminder: 24 * time.Hour,
+	data.NotifyWeeklyDigest:  7 * 24 * time.Hour,
+	data.NotifyNewPacks:      24 * time.Hour,
+}
+
+const notificationSlack = time.Hour
+
+func (app *application) showNotificationSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := app.models.Notifications.Get(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"notifications": settings}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateNotificationSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := app.models.Notifications.Get(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	var input struct {
+		DailyReminder  *bool    `json:"daily_reminder"`
+		WeeklyDigest   *bool    `json:"weekly_digest"`
+		NewPacks       *bool    `json:"new_packs"`
+		FollowedGenres []string `json:"followed_genres"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.DailyReminder != nil {
+		settings.DailyReminder = *input.DailyReminder
+	}
+	if input.WeeklyDigest != nil {
+		settings.WeeklyDigest = *input.WeeklyDigest
+	}
+	if input.NewPacks != nil {
+		settings.NewPacks = *input.NewPacks
+	}
+	if input.FollowedGenres != nil {
+		settings.FollowedGenres = input.FollowedGenres
+	}
+	v := validator.New()
+	settings.FollowedGenres, err = app.resolveGenres(v, "followed_genres", settings.FollowedGenres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if data.ValidateNotificationSettings(v, settings); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Notifications.Save(settings)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"notifications": settings}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// confirmUnsubscribeHandler answers the link in the email body. It only
+// checks the token and asks for confirmation: link scanners and prefetching
+// mail clients follow GET links, so they must not change anything.
+func (app *application) confirmUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := app.verifyUnsubscribeToken(r.URL.Query().Get("token")); !ok {
+		v := validator.New()
+		v.AddError("token", "validation.unsubscribe_token")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"message": app.translate(r, "message.confirm_unsubscribe")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// unsubscribeHandler turns notifications off for whoever the signed token in
+// the link was made for. It needs no authentication and answers both the
+// confirmation form and the one-click POST of RFC 8058 mail clients.
+func (app *application) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, kind, ok := app.verifyUnsubscribeToken(r.URL.Query().Get("token"))
+	if !ok {
+		v := validator.New()
+		v.AddError("token", "validation.unsubscribe_token")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err := app.models.Notifications.Unsubscribe(userID, kind)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": app.translate(r, "message.unsubscribed")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// unsubscribeToken signs a user id and notification kind. The token does not
+// expire, since an old email should still be able to stop the new ones;
+// changing the secret invalidates every link sent so far.
+func (app *application) unsubscribeToken(userID int64, kind string) string {
+	payload := strconv.FormatInt(userID, 10) + "." + kind
+	return payload + "." + app.unsubscribeSignature(payload)
+}
+
+func (app *application) unsubscribeSignature(payload string) string {
+	mac := hmac.New(sha256.New, []byte(app.config.notifications.secret))
+	mac.Write([]byte("unsubscribe:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (app *application) verifyUnsubscribeToken(token string) (int64, string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, "", false
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(app.unsubscribeSignature(payload))) {
+		return 0, "", false
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || userID < 1 {
+		return 0, "", false
+	}
+	return userID, parts[1], true
+}
+
+func (app *application) unsubscribeURL(userID int64, kind string) string {
+	return app.config.publicURL + "/v1/notifications/unsubscribe?token=" + url.QueryEscape(app.unsubscribeToken(userID, kind))
+}
+
+func (app *application) puzzleURL(id int64) string {
+	return fmt.Sprintf("%s/v1/puzzles/%d", app.config.publicURL, id)
+}
+
+// sendNotifications queues the notification emails that are due. It runs as
+// a scheduled task and returns how many emails it queued.
+func (app *application) sendNotifications() (int64, error) {
+	var queued int64
+	for _, kind := range data.NotificationKinds {
+		n, err := app.sendNotification(kind, time.Now())
+		queued += n
+		if err != nil {
+			return queued, err
+		}
+	}
+	return queued, nil
+}
+
+func (app *application) sendNotification(kind string, now time.Time) (int64, error) {
+	period := notificationPeriods[kind]
+	compose, err := app.notificationComposer(kind, now)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var queued int64
+	for {
+		subscribers, err := app.models.Notifications.Due(kind, now.Add(-period+notificationSlack), 100)
+		if err != nil {
+			return queued, err
+		}
+		for _, s := range subscribers {
+			template, templateData, err := compose(s)
+			if err != nil {
+				return queued, err
+			}
+			err = app.models.Notifications.Deliver(kind, s, template, templateData)
+			if err != nil {
+				return queued, err
+			}
+			if template != "" {
+				queued++
+			}
+		}
+		if len(subscribers) < 100 {
+			return queued, nil
+		}
+	}
+}
+
+// notificationComposer returns a function building the email of kind for one
+// subscriber. An empty template means there is nothing to tell them this
+// time. ErrRecordNotFound means there is nothing to send anyone.
+func (app *application) notificationComposer(kind string, now time.Time) (func(s *data.Subscriber) (string, map[string]interface{}, error), error) {
+	switch kind {
+	case data.NotifyDailyReminder:
+		puzzle, err := app.models.Notifications.PuzzleOfTheDay(now)
+		if err != nil {
+			return nil, err
+		}
+		return func(s *data.Subscriber) (string, map[string]interface{}, error) {
+			return "daily_reminder.tmpl", map[string]interface{}{
+				"name":           s.Name,
+				"puzzleTitle":    puzzle.Title,
+				"puzzleURL":      app.puzzleURL(puzzle.ID),
+				"unsubscribeURL": app.unsubscribeURL(s.UserID, kind),
+			}, nil
+		}, nil
+	case data.NotifyWeeklyDigest:
+		return func(s *data.Subscriber) (string, map[string]interface{}, error) {
+			stats, err := app.models.Notifications.WeeklyStats(s.UserID, now.Add(-7*24*time.Hour))
+			if err != nil {
+				return "", nil, err
+			}
+			return "weekly_digest.tmpl", map[string]interface{}{
+				"name":           s.Name,
+				"published":      stats.Published,
+				"ownTotal":       stats.OwnTotal,
+				"ownPublished":   stats.OwnPublished,
+				"ownCreated":     stats.OwnCreated,
+				"unsubscribeURL": app.unsubscribeURL(s.UserID, kind),
+			}, nil
+		}, nil
+	case data.NotifyNewPacks:
+		return func(s *data.Subscriber) (string, map[string]interface{}, error) {
+			if len(s.FollowedGenres) == 0 {
+				return "", nil, nil
+			}
+			since := now.Add(-notificationPeriods[kind])
+			if s.LastSentAt != nil {
+				since = *s.LastSentAt
+			}
+			puzzles, err := app.models.Notifications.NewPacks(s.UserID, s.FollowedGenres, since, 10)
+			if err != nil || len(puzzles) == 0 {
+				return "", nil, err
+			}
+			packs := make([]map[string]interface{}, len(puzzles))
+			for i, puzzle := range puzzles {
+				packs[i] = map[string]interface{}{
+					"title":  puzzle.Title,
+					"genres": strings.Join(puzzle.Genres, ", "),
+					"url":    app.puzzleURL(puzzle.ID),
+				}
+			}
+			return "new_packs.tmpl", map[string]interface{}{
+				"name":           s.Name,
+				"packs":          packs,
+				"unsubscribeURL": app.unsubscribeURL(s.UserID, kind),
+			}, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification kind %q", kind)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newUnsubscribeApp(secret string) *application {
+	app := &application{}
+	app.config.notifications.secret = secret
+	return app
+}
+
+func TestUnsubscribeTokenRoundTrip(t *testing.T) {
+	app := newUnsubscribeApp("secret")
+	token := app.unsubscribeToken(42, data.NotifyWeeklyDigest)
+	userID, kind, ok := app.verifyUnsubscribeToken(token)
+	if !ok || userID != 42 || kind != data.NotifyWeeklyDigest {
+		t.Fatalf("got %d, %q, %v, want 42, %q, true", userID, kind, ok, data.NotifyWeeklyDigest)
+	}
+}
+
+func TestUnsubscribeTokenRejected(t *testing.T) {
+	app := newUnsubscribeApp("secret")
+	token := app.unsubscribeToken(42, data.NotifyWeeklyDigest)
+	signature := token[strings.LastIndex(token, ".")+1:]
+
+	tampered := []byte(token)
+	if tampered[len(tampered)-1] == 'A' {
+		tampered[len(tampered)-1] = 'B'
+	} else {
+		tampered[len(tampered)-1] = 'A'
+	}
+
+	tests := map[string]string{
+		"tampered signature": string(tampered),
+		"wrong kind":         "42." + data.NotifyDailyReminder + "." + signature,
+		"wrong user":         "43." + data.NotifyWeeklyDigest + "." + signature,
+		"other secret":       newUnsubscribeApp("other").unsubscribeToken(42, data.NotifyWeeklyDigest),
+		"missing signature":  "42." + data.NotifyWeeklyDigest,
+		"empty":              "",
+	}
+	for name, token := range tests {
+		if _, _, ok := app.verifyUnsubscribeToken(token); ok {
+			t.Fatalf("%s: token %q accepted", name, token)
+		}
+	}
+}
+
+func TestConfirmUnsubscribeChangesNothing(t *testing.T) {
+	// The application has no models, so reaching the database would panic.
+	app := newUnsubscribeApp("secret")
+	token := url.QueryEscape(app.unsubscribeToken(42, data.NotifyWeeklyDigest))
+	req := httptest.NewRequest(http.MethodGet, "/v1/notifications/unsubscribe?token="+token, nil)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rr.Code, rr.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/notifications/unsubscribe?token=bad", nil)
+	rr = httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got status %d for a bad token, want 422", rr.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// runOutbox starts the dispatcher that delivers queued emails and returns a
+// function that stops it, waiting for the email being sent to finish.
+func (app *application) runOutbox() (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			// Keep sending while emails are due and only sleep once the
+			// outbox is empty.
+			wait := app.config.outbox.pollInterval
+			if app.sendNextEmail() {
+				wait = 0
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// sendNextEmail claims and delivers one email. It returns false when nothing
+// was due or the outbox could not be read.
+func (app *application) sendNextEmail() bool {
+	email, err := app.models.Outbox.Claim()
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logger.PrintError(err, nil)
+		}
+		return false
+	}
+	properties := map[string]string{
+		"email":    strconv.FormatInt(email.ID, 10),
+		"template": email.Template,
+	}
+	err = app.deliverEmail(email)
+	if err == nil {
+		err = app.models.Outbox.MarkSent(email)
+	} else {
+		app.logger.PrintError(err, properties)
+		err = app.models.Outbox.MarkFailed(email, err, time.Now().Add(retryDelay(email.Attempts)))
+		if err == nil && email.Status == data.EmailFailed {
+			app.logger.PrintInfo("email failed after its last attempt", properties)
+		}
+	}
+	if err != nil {
+		app.logger.PrintError(err, properties)
+	}
+	return true
+}
+
+// deliverEmail renders and sends an email. Numbers in the template data are
+// kept as json.Number so that ids print the way they were written.
+func (app *application) deliverEmail(email *data.Email) (err error) {
+	var templateData interface{}
+	dec := json.NewDecoder(bytes.NewReader(email.Data))
+	dec.UseNumber()
+	err = dec.Decode(&templateData)
+	if err != nil {
+		return fmt.Errorf("invalid template data: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sending email panicked: %v", r)
+		}
+	}()
+	return app.mailer.Send(email.Locale, email.Recipient, email.Template, templateData)
+}
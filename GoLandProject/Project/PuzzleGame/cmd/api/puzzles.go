@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -25,9 +26,15 @@ func (app *application) createPuzzleHandler(w http.ResponseWriter, r *http.Reque
 		Title:        input.Title,
 		NumOfPuzzles: input.NumOfPuzzles,
 		Genres:       input.Genres,
+		OwnerID:      app.contextGetUser(r).ID,
 	}
 
 	v := validator.New()
+	puzzle.Genres, err = app.resolveGenres(v, "genres", puzzle.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 	if data.ValidateMovie(v, puzzle); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -40,6 +47,7 @@ func (app *application) createPuzzleHandler(w http.ResponseWriter, r *http.Reque
 	}
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/puzzles/%d", puzzle.ID))
+	headers.Set("ETag", puzzleReadETag(puzzle))
 	err = app.writeJSON(w, http.StatusCreated, envelope{"puzzle": puzzle}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -62,6 +70,18 @@ func (app *application) showPuzzleHandler(w http.ResponseWriter, r *http.Request
 		}
 		return
 	}
+	visible, err := app.canViewPuzzle(r, puzzle)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !visible {
+		app.notFoundResponse(w, r)
+		return
+	}
+	if app.notModified(w, r, puzzleReadETag(puzzle)) {
+		return
+	}
 	err = app.writeJSON(w, http.StatusOK, envelope{"puzzle": puzzle}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -103,6 +123,18 @@ func (app *application) updatePuzzleHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
+	allowed, err := app.canModifyPuzzle(r, puzzle)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+	if app.puzzlePreconditionFailed(w, r, puzzle) {
+		return
+	}
 
 	var input struct {
 		Title        *string   `json:"title"`
@@ -124,13 +156,20 @@ func (app *application) updatePuzzleHandler(w http.ResponseWriter, r *http.Reque
 		puzzle.Genres = input.Genres
 	}
 	v := validator.New()
+	puzzle.Genres, err = app.resolveGenres(v, "genres", puzzle.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 	if data.ValidateMovie(v, puzzle); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.models.Puzzles.Update(puzzle)
+	err = app.models.Puzzles.Update(puzzle, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
 		default:
@@ -138,7 +177,9 @@ func (app *application) updatePuzzleHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"puzzle": puzzle}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", puzzleReadETag(puzzle))
+	err = app.writeJSON(w, http.StatusOK, envelope{"puzzle": puzzle}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -150,7 +191,7 @@ func (app *application) deletePuzzleHandler(w http.ResponseWriter, r *http.Reque
 		app.notFoundResponse(w, r)
 		return
 	}
-	err = app.models.Puzzles.Delete(id)
+	puzzle, err := app.models.Puzzles.Get(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -160,37 +201,243 @@ func (app *application) deletePuzzleHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	allowed, err := app.canModifyPuzzle(r, puzzle)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+	if app.puzzlePreconditionFailed(w, r, puzzle) {
+		return
+	}
+	err = app.models.Puzzles.Delete(puzzle, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": app.translate(r, "message.puzzle_trashed")}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 func (app *application) listPuzzlesHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Title  string
-		Genres []string
-		data.Filters
+	v := validator.New()
+	input := app.readPuzzleListInput(r.URL.Query(), data.StatusPublished, v)
+	moderator, err := app.userHasPermission(r, "puzzles:moderate")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
+	if !moderator {
+		v.Check(input.Status == data.StatusPublished, "status", "validation.published_only")
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	puzzles, metadata, err := app.models.Puzzles.GetAll(input.Title, input.Genres, input.Status, input.MinRating, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if app.notModified(w, r, puzzleListETag(puzzles, metadata)) {
+		return
+	}
+	// Include the metadata in the response envelope.
+	err = app.writeJSON(w, http.StatusOK, envelope{"puzzles": puzzles, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listOwnPuzzlesHandler(w http.ResponseWriter, r *http.Request) {
 	v := validator.New()
-	qs := r.URL.Query()
+	input := app.readPuzzleListInput(r.URL.Query(), "", v)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	user := app.contextGetUser(r)
+	puzzles, metadata, err := app.models.Puzzles.GetAllForOwner(user.ID, input.Title, input.Genres, input.Status, input.MinRating, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"puzzles": puzzles, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+type puzzleListInput struct {
+	Title     string
+	Genres    []string
+	Status    string
+	MinRating float64
+	data.Filters
+}
+
+func (app *application) readPuzzleListInput(qs url.Values, defaultStatus string, v *validator.Validator) puzzleListInput {
+	var input puzzleListInput
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.Status = app.readString(qs, "status", defaultStatus)
+	if input.Status != "" {
+		v.Check(validator.In(input.Status, data.Statuses...), "status", "validation.status")
+	}
+	input.MinRating = app.readFloat(qs, "min_rating", 0, v)
+	v.Check(input.MinRating >= 0 && input.MinRating <= 5, "min_rating", "validation.between", 0, 5)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "num_of_puzzles", "-id", "-title", "-num_of_puzzles"}
-	if data.ValidateFilters(v, input.Filters); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+	input.Filters.SortSafelist = []string{"id", "title", "num_of_puzzles", "rating", "-id", "-title", "-num_of_puzzles", "-rating"}
+	input.Filters.UseCursor = qs.Has("cursor")
+	input.Filters.Cursor = qs.Get("cursor")
+	return input
+}
+
+func (app *application) publishPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	app.transitionPuzzle(w, r, data.StatusInReview, false)
+}
+
+func (app *application) approvePuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	app.transitionPuzzle(w, r, data.StatusPublished, true)
+}
+
+func (app *application) rejectPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	app.transitionPuzzle(w, r, data.StatusDraft, true)
+}
+
+func (app *application) archivePuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	app.transitionPuzzle(w, r, data.StatusArchived, false)
+}
+
+// transitionPuzzle moves a puzzle to the given status. Moderator transitions
+// are already guarded by the puzzles:moderate permission on the route, the
+// rest are limited to the owner of the pack.
+func (app *application) transitionPuzzle(w http.ResponseWriter, r *http.Request, status string, moderator bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
 		return
 	}
-	puzzles, metadata, err := app.models.Puzzles.GetAll(input.Title, input.Genres, input.Filters)
+	puzzle, err := app.models.Puzzles.Get(id)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-	// Include the metadata in the response envelope.
-	err = app.writeJSON(w, http.StatusOK, envelope{"puzzles": puzzles, "metadata": metadata}, nil)
+	if !moderator {
+		allowed, err := app.canModifyPuzzle(r, puzzle)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !allowed {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+	if app.puzzlePreconditionFailed(w, r, puzzle) {
+		return
+	}
+	from := puzzle.Status
+	err = app.models.Puzzles.Transition(puzzle, status, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidTransition):
+			app.invalidStatusTransitionResponse(w, r, from, status)
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", puzzleReadETag(puzzle))
+	err = app.writeJSON(w, http.StatusOK, envelope{"puzzle": puzzle}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// readModifiablePuzzle loads the puzzle named in the URL and checks that the
+// current user may change it. On failure it writes the error response itself
+// and returns nil.
+func (app *application) readModifiablePuzzle(w http.ResponseWriter, r *http.Request) *data.Puzzle {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+	puzzle, err := app.models.Puzzles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	allowed, err := app.canModifyPuzzle(r, puzzle)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return nil
+	}
+	return puzzle
+}
+
+func (app *application) userHasPermission(r *http.Request, code string) (bool, error) {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return false, nil
+	}
+	permissions, err := app.getPermissionsForUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Include(code), nil
+}
+
+func (app *application) canModifyPuzzle(r *http.Request, puzzle *data.Puzzle) (bool, error) {
+	if puzzle.OwnerID == app.contextGetUser(r).ID {
+		return true, nil
+	}
+	return app.userHasPermission(r, "puzzles:write:any")
+}
+
+// canViewPuzzle reports whether the puzzle is visible to the current user.
+// Only published packs are public; everything else is limited to the owner
+// and to moderators.
+func (app *application) canViewPuzzle(r *http.Request, puzzle *data.Puzzle) (bool, error) {
+	if puzzle.Status == data.StatusPublished || puzzle.OwnerID == app.contextGetUser(r).ID {
+		return true, nil
+	}
+	moderator, err := app.userHasPermission(r, "puzzles:moderate")
+	if err != nil || moderator {
+		return moderator, err
+	}
+	return app.userHasPermission(r, "puzzles:write:any")
+}
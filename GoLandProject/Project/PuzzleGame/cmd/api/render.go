@@ -0,0 +1,83 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/formats"
+	"Puzzle.Ayan.net/internal/render"
+	"Puzzle.Ayan.net/internal/validator"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var renderFormats = map[string]string{
+	"svg": "image/svg+xml",
+	"pdf": "application/pdf",
+	"png": "image/png",
+}
+
+// renderPuzzleItemHandler draws a printable version of an item. With
+// solution=true the solution follows on a page of its own.
+func (app *application) renderPuzzleItemHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	format := app.readString(qs, "format", "pdf")
+	v.Check(renderFormats[format] != "", "format", "validation.one_of", "svg, pdf, png")
+	solution := app.readBool(qs, "solution", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	stored := app.readViewableItem(w, r)
+	if stored == nil {
+		return
+	}
+	item, err := formats.DecodeItem(stored.Kind, stored.Title, stored.Content)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	pages, err := render.Pages(item, solution)
+	if err != nil {
+		switch {
+		case errors.Is(err, render.ErrNoSolution):
+			v.AddError("solution", "validation.no_solution")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, formats.ErrUnsupported):
+			v.AddError("item", "validation.not_printable")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var draw func(io.Writer, []render.Page) error
+	switch format {
+	case "svg":
+		draw = render.SVG
+	case "pdf":
+		draw = render.PDF
+	case "png":
+		draw = render.PNG
+	}
+	// Render into memory first so that a failure can still be reported as
+	// an error response.
+	var buf bytes.Buffer
+	err = draw(&buf, pages)
+	if err != nil {
+		switch {
+		case errors.Is(err, render.ErrUnsupportedText):
+			v.AddError("format", "validation.pdf_text")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", renderFormats[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="puzzle-%d-item-%d.%s"`, stored.PuzzleID, stored.ID, format))
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
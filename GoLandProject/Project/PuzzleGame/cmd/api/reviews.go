@@ -0,0 +1,223 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) listReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	puzzle := app.readViewablePuzzle(w, r)
+	if puzzle == nil {
+		return
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	var filters data.Filters
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-id")
+	filters.SortSafelist = []string{"id", "rating", "updated_at", "-id", "-rating", "-updated_at"}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	reviews, metadata, err := app.models.Reviews.GetAll(puzzle.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"reviews": reviews, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createReviewHandler rates a published puzzle for the current user. Owners
+// cannot rate their own packs, and everyone else gets one review per pack,
+// which they edit rather than post again.
+func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Request) {
+	puzzle := app.readViewablePuzzle(w, r)
+	if puzzle == nil {
+		return
+	}
+	var input struct {
+		Rating int    `json:"rating"`
+		Body   string `json:"body"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	user := app.contextGetUser(r)
+	review := &data.Review{
+		PuzzleID: puzzle.ID,
+		UserID:   user.ID,
+		UserName: user.Name,
+		Rating:   input.Rating,
+		Body:     input.Body,
+	}
+	v := validator.New()
+	v.Check(puzzle.Status == data.StatusPublished, "puzzle", "validation.review_published")
+	v.Check(puzzle.OwnerID != user.ID, "puzzle", "validation.review_own")
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateReview):
+			v.AddError("puzzle", "validation.review_exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/puzzles/%d/reviews/%d", puzzle.ID, review.ID))
+	headers.Set("ETag", reviewETag(review))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"review": review}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review := app.readReview(w, r)
+	if review == nil {
+		return
+	}
+	if app.notModified(w, r, reviewETag(review)) {
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateReviewHandler lets the author change their rating and text. The
+// version locking of the row turns concurrent edits into an edit conflict,
+// and an If-Match header can be sent to also catch edits made since the
+// review was fetched.
+func (app *application) updateReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review := app.readReview(w, r)
+	if review == nil {
+		return
+	}
+	if review.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+	if app.preconditionFailed(w, r, reviewETag(review)) {
+		return
+	}
+	var input struct {
+		Rating *int    `json:"rating"`
+		Body   *string `json:"body"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Rating != nil {
+		review.Rating = *input.Rating
+	}
+	if input.Body != nil {
+		review.Body = *input.Body
+	}
+	v := validator.New()
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Reviews.Update(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", reviewETag(review))
+	err = app.writeJSON(w, http.StatusOK, envelope{"review": review}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteReviewHandler removes a review. Besides the author, moderators may
+// remove reviews that break the rules.
+func (app *application) deleteReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review := app.readReview(w, r)
+	if review == nil {
+		return
+	}
+	if review.UserID != app.contextGetUser(r).ID {
+		moderator, err := app.userHasPermission(r, "puzzles:moderate")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !moderator {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+	if app.preconditionFailed(w, r, reviewETag(review)) {
+		return
+	}
+	err := app.models.Reviews.Delete(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": app.translate(r, "message.review_deleted")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readReview loads the review named by the :review parameter from the puzzle
+// named by :id, writing a 404 and returning nil when either does not exist or
+// the puzzle is not visible to the user.
+func (app *application) readReview(w http.ResponseWriter, r *http.Request) *data.Review {
+	puzzle := app.readViewablePuzzle(w, r)
+	if puzzle == nil {
+		return nil
+	}
+	id, err := app.readReviewParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+	review, err := app.models.Reviews.Get(puzzle.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	return review
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/validator"
+	"errors"
+	"net/http"
+)
+
+func (app *application) listPuzzleRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	puzzle := app.readModifiablePuzzle(w, r)
+	if puzzle == nil {
+		return
+	}
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-version"
+	input.Filters.SortSafelist = []string{"-version"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	revisions, metadata, err := app.models.Revisions.GetAll(puzzle.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPuzzleRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	puzzle := app.readModifiablePuzzle(w, r)
+	if puzzle == nil {
+		return
+	}
+	if app.puzzlePreconditionFailed(w, r, puzzle) {
+		return
+	}
+	version, err := app.readVersionParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	revision, err := app.models.Revisions.Get(puzzle.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// restorePuzzleRevisionHandler copies the content of an old revision back
+// onto the puzzle. The restore is saved as a new revision rather than
+// rewinding the version counter, and the lifecycle status is left alone. The
+// old content is checked like any other edit, with its genres resolved
+// against the current list, since the rules may have changed since.
+func (app *application) restorePuzzleRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	puzzle := app.readModifiablePuzzle(w, r)
+	if puzzle == nil {
+		return
+	}
+	if app.puzzlePreconditionFailed(w, r, puzzle) {
+		return
+	}
+	version, err := app.readVersionParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	revision, err := app.models.Revisions.Get(puzzle.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	puzzle.Title = revision.Title
+	puzzle.NumOfPuzzles = revision.NumOfPuzzles
+	v := validator.New()
+	puzzle.Genres, err = app.resolveGenres(v, "genres", revision.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if data.ValidateMovie(v, puzzle); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Puzzles.Update(puzzle, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", puzzleReadETag(puzzle))
+	err = app.writeJSON(w, http.StatusOK, envelope{"puzzle": puzzle}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
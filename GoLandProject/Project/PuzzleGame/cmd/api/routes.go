@@ -1,6 +1,7 @@
 package main
 
 import (
+	"expvar"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 )
@@ -12,11 +13,99 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/puzzles", app.requirePermission("puzzles:read", app.listPuzzlesHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/puzzles", app.requirePermission("puzzles:write", app.createPuzzleHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id", app.requirePermission("puzzles:read", app.showPuzzleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/puzzles/:id", app.routeByID(map[string]http.HandlerFunc{
+		"import": app.requirePermission("puzzles:write", app.importPuzzlesHandler),
+	}, nil))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id", app.routeByID(map[string]http.HandlerFunc{
+		"export": app.requirePermission("puzzles:read", app.exportPuzzlesHandler),
+	}, app.requirePermission("puzzles:read", app.showPuzzleHandler)))
 	router.HandlerFunc(http.MethodPatch, "/v1/puzzles/:id", app.requirePermission("puzzles:write", app.updatePuzzleHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/puzzles/:id", app.requirePermission("puzzles:write", app.deletePuzzleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/items", app.requirePermission("puzzles:read", app.listPuzzleItemsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/items/:item", app.requirePermission("puzzles:read", app.showPuzzleItemHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/items/:item/render", app.requirePermission("puzzles:read", app.renderPuzzleItemHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/items/:item/image", app.requirePermission("puzzles:read", app.showItemImageHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/items/:item/thumbnail", app.requirePermission("puzzles:read", app.showItemThumbnailHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/thumbnail", app.requirePermission("puzzles:read", app.showPuzzleThumbnailHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/puzzles/:id/publish", app.requirePermission("puzzles:write", app.publishPuzzleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/puzzles/:id/approve", app.requirePermission("puzzles:moderate", app.approvePuzzleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/puzzles/:id/reject", app.requirePermission("puzzles:moderate", app.rejectPuzzleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/puzzles/:id/archive", app.requirePermission("puzzles:write", app.archivePuzzleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/puzzles/:id/restore", app.requirePermission("puzzles:write", app.restorePuzzleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/reviews", app.requirePermission("puzzles:read", app.listReviewsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/puzzles/:id/reviews", app.requirePermission("puzzles:read", app.createReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/reviews/:review", app.requirePermission("puzzles:read", app.showReviewHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/puzzles/:id/reviews/:review", app.requirePermission("puzzles:read", app.updateReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/puzzles/:id/reviews/:review", app.requirePermission("puzzles:read", app.deleteReviewHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/puzzles/:id/favorite", app.requirePermission("puzzles:read", app.addFavoriteHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/puzzles/:id/favorite", app.requirePermission("puzzles:read", app.removeFavoriteHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/uploads/puzzles", app.requirePermission("puzzles:write", app.uploadPuzzleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/uploads/jigsaws", app.requirePermission("puzzles:write", app.createJigsawHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.requirePermission("puzzles:read", app.listGenresHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission("puzzles:moderate", app.createGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:id", app.requirePermission("puzzles:moderate", app.updateGenreHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/trash", app.requirePermission("puzzles:write", app.listTrashHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/revisions", app.requirePermission("puzzles:write", app.listPuzzleRevisionsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id/revisions/:version", app.requirePermission("puzzles:write", app.showPuzzleRevisionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/puzzles/:id/revisions/:version/restore", app.requirePermission("puzzles:write", app.restorePuzzleRevisionHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/puzzles", app.requireActivatedUser(app.listOwnPuzzlesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/favorites", app.requirePermission("puzzles:read", app.listFavoritesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/collections", app.requirePermission("puzzles:read", app.listOwnCollectionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/collections", app.requirePermission("puzzles:read", app.createCollectionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id", app.showCollectionHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id", app.requirePermission("puzzles:read", app.updateCollectionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id", app.requirePermission("puzzles:read", app.deleteCollectionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/collections/:id/entries", app.listCollectionEntriesHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/collections/:id/entries", app.requirePermission("puzzles:read", app.addCollectionEntryHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/collections/:id/entries/:entry", app.requirePermission("puzzles:read", app.moveCollectionEntryHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/collections/:id/entries/:entry", app.requirePermission("puzzles:read", app.removeCollectionEntryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/notifications", app.requireActivatedUser(app.showNotificationSettingsHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me/notifications", app.requireActivatedUser(app.updateNotificationSettingsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/notifications/unsubscribe", app.confirmUnsubscribeHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/notifications/unsubscribe", app.unsubscribeHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
-	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users", app.requirePermission("admin:users", app.listUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id", app.requirePermission("admin:users", app.showUserHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/users/:id", app.requirePermission("admin:users", app.updateUserActivationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/permissions", app.requirePermission("admin:users", app.grantUserPermissionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/permissions", app.requirePermission("admin:users", app.revokeUserPermissionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/roles", app.requirePermission("admin:users", app.grantUserRolesHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/roles", app.requirePermission("admin:users", app.revokeUserRolesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/roles", app.requirePermission("admin:users", app.listRolesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/audit-log", app.requirePermission("admin:users", app.listAuditLogHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/jobs", app.requirePermission("admin:jobs", app.listJobsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/jobs/:id", app.requirePermission("admin:jobs", app.showJobHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/jobs/:id/retry", app.requirePermission("admin:jobs", app.retryJobHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/emails", app.requirePermission("admin:jobs", app.listEmailsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/emails/:id", app.requirePermission("admin:jobs", app.showEmailHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/emails/:id/retry", app.requirePermission("admin:jobs", app.retryEmailHandler))
+	router.HandlerFunc(http.MethodGet, "/debug/vars", app.requirePermission("admin:users", expvar.Handler().ServeHTTP))
+	// The mail preview has no authentication, so it is only served when
+	// explicitly asked for.
+	if app.config.mail.devMail {
+		router.HandlerFunc(http.MethodGet, "/dev/mail", app.devMailHandler)
+		router.HandlerFunc(http.MethodGet, "/dev/mail/templates/:name", app.devMailTemplateHandler)
+	}
+	return app.recoverPanic(app.varyByLanguage(app.enableCORS(app.rateLimit(app.authenticate(router)))))
+}
+
+// routeByID dispatches on the value of the :id parameter. httprouter does not
+// allow a static segment such as /v1/puzzles/import next to the /v1/puzzles/:id
+// wildcard, so collection-level actions are registered on the wildcard route
+// and picked out here. Other values go to next, or get a 404 if next is nil.
+func (app *application) routeByID(actions map[string]http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if action, ok := actions[params.ByName("id")]; ok {
+			action(w, r)
+			return
+		}
+		if next == nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+		next(w, r)
+	}
 }
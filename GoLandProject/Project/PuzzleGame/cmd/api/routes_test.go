@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPuzzleCollectionRoutes(t *testing.T) {
+	app := &application{}
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		// Registered on the /v1/puzzles/:id wildcard and picked out by
+		// routeByID, so they need a login rather than being unknown.
+		{http.MethodPost, "/v1/puzzles/import", http.StatusUnauthorized},
+		{http.MethodPost, "/v1/puzzles/unknown", http.StatusNotFound},
+		{http.MethodPost, "/v1/uploads/puzzles", http.StatusUnauthorized},
+		{http.MethodPost, "/v1/puzzles/upload", http.StatusNotFound},
+		{http.MethodPost, "/v1/uploads/jigsaws", http.StatusUnauthorized},
+		{http.MethodPost, "/v1/puzzles/jigsaw", http.StatusNotFound},
+		{http.MethodGet, "/v1/puzzles/export", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/puzzles/export?format=csv", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+		if rr.Code != tt.want {
+			t.Fatalf("%s %s: got status %d, want %d", tt.method, tt.path, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestRouteByID(t *testing.T) {
+	app := &application{}
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}
+	}
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/v1/puzzles/:id", app.routeByID(map[string]http.HandlerFunc{
+		"export": handler("export"),
+	}, handler("show")))
+	for path, want := range map[string]string{
+		"/v1/puzzles/export":            "export",
+		"/v1/puzzles/export?format=csv": "export",
+		"/v1/puzzles/12":                "show",
+		"/v1/puzzles/exports":           "show",
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Body.String() != want {
+			t.Fatalf("GET %s went to %q, want %q", path, rr.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// scheduledTask is maintenance work that runs every interval. Every replica
+// schedules every task, and the database decides which one actually runs it,
+// so run returning a count of affected rows is all a task has to provide.
+type scheduledTask struct {
+	name     string
+	interval time.Duration
+	run      func() (int64, error)
+}
+
+// due reports whether the task should run again now that it last ran at
+// lastRun. Replicas tick at slightly different moments, so a run counts for
+// the interval if it started within the last nine tenths of it.
+func (task scheduledTask) due(lastRun, now time.Time) bool {
+	return now.Sub(lastRun) >= task.interval*9/10
+}
+
+func (app *application) scheduledTasks() []scheduledTask {
+	return []scheduledTask{
+		{
+			name:     "tokens.purge_expired",
+			interval: time.Hour,
+			run:      app.models.Tokens.DeleteExpired,
+		},
+		{
+			name:     "users.purge_unactivated",
+			interval: time.Hour,
+			run: func() (int64, error) {
+				return app.models.Users.DeleteUnactivated(time.Now().Add(-app.config.users.unactivatedRetention))
+			},
+		},
+		{
+			name:     "trash.purge",
+			interval: app.config.trash.purgeInterval,
+			run:      app.purgeTrash,
+		},
+		{
+			// Jobs left running by a worker that died are put back in the
+			// queue once they have been locked for twice the job timeout.
+			name:     "jobs.release_stale",
+			interval: time.Minute,
+			run: func() (int64, error) {
+				return app.models.Jobs.ReleaseStale(time.Now().Add(-2 * app.config.jobs.timeout))
+			},
+		},
+		{
+			name:     "jobs.purge_succeeded",
+			interval: time.Hour,
+			run: func() (int64, error) {
+				return app.models.Jobs.DeleteSucceeded(time.Now().Add(-app.config.jobs.retention))
+			},
+		},
+		{
+			name:     "notifications.send",
+			interval: time.Hour,
+			run:      app.sendNotifications,
+		},
+		{
+			// An email is never sending for longer than the SMTP timeouts
+			// allow, so one locked for ten minutes lost its dispatcher.
+			name:     "outbox.release_stale",
+			interval: time.Minute,
+			run: func() (int64, error) {
+				return app.models.Outbox.ReleaseStale(time.Now().Add(-10 * time.Minute))
+			},
+		},
+		{
+			name:     "outbox.purge_sent",
+			interval: time.Hour,
+			run: func() (int64, error) {
+				return app.models.Outbox.DeleteSent(time.Now().Add(-app.config.outbox.retention))
+			},
+		},
+	}
+}
+
+// runScheduler starts a goroutine per scheduled task and returns a function
+// that stops them, waiting for running tasks to finish.
+func (app *application) runScheduler() (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, task := range app.scheduledTasks() {
+		task := task
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The first run waits a little so that a restarting server does
+			// not compete with the database while it warms up.
+			wait := min(task.interval, time.Minute)
+			for {
+				select {
+				case <-done:
+					return
+				case <-time.After(wait):
+				}
+				wait = task.interval
+				app.runScheduledTask(task)
+			}
+		}()
+	}
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (app *application) runScheduledTask(task scheduledTask) {
+	var count int64
+	ran, err := app.models.Tasks.RunExclusive(task.name, task.due, func() error {
+		var err error
+		count, err = task.run()
+		return err
+	})
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"task": task.name})
+		return
+	}
+	if ran && count > 0 {
+		app.logger.PrintInfo("scheduled task finished", map[string]string{
+			"task":  task.name,
+			"count": strconv.FormatInt(count, 10),
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledTaskDue(t *testing.T) {
+	task := scheduledTask{name: "test", interval: time.Hour}
+	lastRun := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		since time.Duration
+		due   bool
+	}{
+		{0, false},
+		{30 * time.Minute, false},
+		// Another replica ran the task a little less than an interval ago.
+		{53 * time.Minute, false},
+		{54*time.Minute - time.Second, false},
+		{54 * time.Minute, true},
+		{time.Hour, true},
+		{3 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		if due := task.due(lastRun, lastRun.Add(tt.since)); due != tt.due {
+			t.Fatalf("%v after the last run: got due %v, want %v", tt.since, due, tt.due)
+		}
+	}
+}
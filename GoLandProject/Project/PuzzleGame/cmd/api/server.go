@@ -19,6 +19,13 @@ func (app *application) serve() error {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	stopJobs := app.runJobs()
+	stopOutbox := app.runOutbox()
+	stopScheduler := app.runScheduler()
+	stopSweeper := func() {}
+	if app.authCache != nil {
+		stopSweeper = app.authCache.runSweeper()
+	}
 	shutdownError := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -37,6 +44,10 @@ func (app *application) serve() error {
 			"addr": srv.Addr,
 		})
 		app.wg.Wait()
+		stopScheduler()
+		stopOutbox()
+		stopJobs()
+		stopSweeper()
 		shutdownError <- nil
 	}()
 	app.logger.PrintInfo("starting server", map[string]string{
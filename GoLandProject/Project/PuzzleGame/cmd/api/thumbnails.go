@@ -0,0 +1,121 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/formats"
+	"Puzzle.Ayan.net/internal/render"
+	"Puzzle.Ayan.net/internal/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const thumbnailSize = 320
+
+// queueThumbnail queues the generation of the preview of an item in the batch
+// that inserts it, so that an item is never saved without its job.
+func (app *application) queueThumbnail(batch *data.PuzzleBatch, item *data.PuzzleItem) error {
+	return batch.Enqueue(jobThumbnail, thumbnailPayload{PuzzleID: item.PuzzleID, ItemID: item.ID})
+}
+
+// generateThumbnail renders the preview of an item. Rendering decodes whole
+// images, so at most -thumbnail-workers previews are made at once, however
+// many job workers are running; the others wait for a free slot.
+func (app *application) generateThumbnail(ctx context.Context, stored *data.PuzzleItem) error {
+	select {
+	case app.thumbnails <- struct{}{}:
+		defer func() { <-app.thumbnails }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	item, err := formats.DecodeItem(stored.Kind, stored.Title, stored.Content)
+	if err != nil {
+		return err
+	}
+	var img image.Image
+	switch content := item.Content.(type) {
+	case *formats.Jigsaw:
+		img, err = app.decodeBlobImage(ctx, content.Image)
+		if err != nil {
+			return err
+		}
+	default:
+		pages, err := render.Pages(item, false)
+		if err != nil {
+			return err
+		}
+		img = render.PageImage(pages[0], thumbnailSize*2)
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, render.Thumbnail(img, thumbnailSize))
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("thumbnails/items/%d.png", stored.ID)
+	err = app.blobs.Put(ctx, key, &buf, "image/png")
+	if err != nil {
+		return err
+	}
+	return app.models.Items.SetThumbnail(stored.ID, key)
+}
+
+func (app *application) decodeBlobImage(ctx context.Context, key string) (image.Image, error) {
+	blob, _, err := app.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	img, _, err := image.Decode(blob)
+	return img, err
+}
+
+func (app *application) showPuzzleThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	puzzle := app.readViewablePuzzle(w, r)
+	if puzzle == nil {
+		return
+	}
+	app.serveBlob(w, r, puzzle.Thumbnail)
+}
+
+func (app *application) showItemThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	item := app.readViewableItem(w, r)
+	if item == nil {
+		return
+	}
+	app.serveBlob(w, r, item.Thumbnail)
+}
+
+// serveBlob streams a blob to the client, answering 404 for an empty key or a
+// blob that is missing from the store.
+func (app *application) serveBlob(w http.ResponseWriter, r *http.Request, key string) {
+	if key == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
+	blob, info, err := app.blobs.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer blob.Close()
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, blob)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
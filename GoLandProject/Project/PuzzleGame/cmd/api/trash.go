@@ -0,0 +1,109 @@
+package main
+
+import (
+	"Puzzle.Ayan.net/internal/data"
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+func (app *application) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-deleted_at"
+	input.Filters.SortSafelist = []string{"-deleted_at"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	ownerID := app.contextGetUser(r).ID
+	writeAny, err := app.userHasPermission(r, "puzzles:write:any")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if writeAny {
+		ownerID = 0
+	}
+	puzzles, metadata, err := app.models.Puzzles.GetAllDeleted(ownerID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"puzzles": puzzles, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restorePuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	puzzle, err := app.models.Puzzles.GetDeleted(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	allowed, err := app.canModifyPuzzle(r, puzzle)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !allowed {
+		app.notPermittedResponse(w, r)
+		return
+	}
+	if app.puzzlePreconditionFailed(w, r, puzzle) {
+		return
+	}
+	err = app.models.Puzzles.Restore(puzzle, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("ETag", puzzleReadETag(puzzle))
+	err = app.writeJSON(w, http.StatusOK, envelope{"puzzle": puzzle}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeTrash removes puzzles that have been in the trash for longer than the
+// configured retention period, together with their blobs. It runs as a
+// scheduled task.
+func (app *application) purgeTrash() (int64, error) {
+	purged, blobs, err := app.models.Puzzles.Purge(time.Now().Add(-app.config.trash.retention))
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range blobs {
+		err := app.blobs.Delete(context.Background(), key)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"blob": key})
+		}
+	}
+	return purged, nil
+}
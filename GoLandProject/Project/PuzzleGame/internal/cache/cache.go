@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value  V
+	expiry time.Time
+}
+
+type Stats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+type Cache[K comparable, V any] struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[K]entry[V]
+	hits    int64
+	misses  int64
+}
+
+func New[K comparable, V any](ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		ttl:     ttl,
+		entries: make(map[K]entry[V]),
+	}
+}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, found := c.entries[key]
+	if !found || time.Now().After(e.expiry) {
+		if found {
+			delete(c.entries, key)
+		}
+		c.misses++
+		var zero V
+		return zero, false
+	}
+	c.hits++
+	return e.value, true
+}
+
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry[V]{value: value, expiry: time.Now().Add(c.ttl)}
+}
+
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// DeleteFunc removes every entry for which fn returns true. It is used when
+// the key alone is not enough to find stale entries, e.g. all tokens that
+// belong to one user.
+func (c *Cache[K, V]) DeleteFunc(fn func(key K, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if fn(key, e.value) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *Cache[K, V]) Sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for key, e := range c.entries {
+		if now.After(e.expiry) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: len(c.entries),
+	}
+}
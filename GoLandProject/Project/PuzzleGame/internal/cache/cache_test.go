@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetSet(t *testing.T) {
+	c := New[string, int](time.Minute)
+	if _, found := c.Get("a"); found {
+		t.Fatalf("empty cache returned a value")
+	}
+	c.Set("a", 1)
+	c.Set("a", 2)
+	if value, found := c.Get("a"); !found || value != 2 {
+		t.Fatalf("got %d, %v, want 2, true", value, found)
+	}
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New[string, int](10 * time.Millisecond)
+	c.Set("a", 1)
+	time.Sleep(20 * time.Millisecond)
+	if _, found := c.Get("a"); found {
+		t.Fatalf("expired entry returned")
+	}
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Fatalf("expired entry kept after Get: %+v", stats)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	c := New[string, int](10 * time.Millisecond)
+	c.Set("old", 1)
+	time.Sleep(20 * time.Millisecond)
+	c.Set("new", 2)
+	c.Sweep()
+	if stats := c.Stats(); stats.Entries != 1 {
+		t.Fatalf("sweep left %d entries, want 1", stats.Entries)
+	}
+	if _, found := c.Get("new"); !found {
+		t.Fatalf("sweep removed a live entry")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := New[string, int](time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Delete("a")
+	c.DeleteFunc(func(_ string, value int) bool {
+		return value == 2
+	})
+	if _, found := c.Get("a"); found {
+		t.Fatalf("deleted entry returned")
+	}
+	if _, found := c.Get("b"); found {
+		t.Fatalf("entry matched by DeleteFunc returned")
+	}
+	if value, found := c.Get("c"); !found || value != 3 {
+		t.Fatalf("got %d, %v, want 3, true", value, found)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	c := New[int, int](time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Set(j%50, i)
+				c.Get(j % 50)
+				if j%100 == 0 {
+					c.Sweep()
+					c.DeleteFunc(func(key, _ int) bool { return key == i })
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats := c.Stats()
+	if stats.Hits+stats.Misses != 8*1000 {
+		t.Fatalf("counted %d lookups, want %d", stats.Hits+stats.Misses, 8*1000)
+	}
+	if stats.Entries > 50 {
+		t.Fatalf("cache holds %d entries for 50 keys", stats.Entries)
+	}
+}
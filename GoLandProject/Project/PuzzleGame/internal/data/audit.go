@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditUserActivated      = "user.activated"
+	AuditUserDeactivated    = "user.deactivated"
+	AuditPermissionsGranted = "permissions.granted"
+	AuditPermissionsRevoked = "permissions.revoked"
+	AuditRolesGranted       = "roles.granted"
+	AuditRolesRevoked       = "roles.revoked"
+)
+
+type AuditEntry struct {
+	ID           int64                  `json:"id"`
+	CreatedAt    time.Time              `json:"created_at"`
+	ActorID      int64                  `json:"actor_id"`
+	Action       string                 `json:"action"`
+	TargetUserID int64                  `json:"target_user_id"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+}
+
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// insertAudit records the entry in the transaction that makes the change it
+// describes, so that the log never disagrees with the data.
+func insertAudit(ctx context.Context, tx *sql.Tx, entry *AuditEntry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+	query := `
+INSERT INTO audit_log (actor_id, action, target_user_id, details)
+VALUES (NULLIF($1::bigint, 0), $2, NULLIF($3::bigint, 0), $4)
+RETURNING id, created_at`
+	args := []interface{}{entry.ActorID, entry.Action, entry.TargetUserID, details}
+	return tx.QueryRowContext(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// withAudit runs change and records the entry in one transaction.
+func withAudit(db *sql.DB, entry *AuditEntry, change func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = change(ctx, tx)
+	if err != nil {
+		return err
+	}
+	err = insertAudit(ctx, tx, entry)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+func (m AuditModel) GetAll(targetUserID int64, filters Filters) ([]*AuditEntry, Metadata, error) {
+	query := `
+SELECT count(*) OVER(), id, created_at, COALESCE(actor_id, 0), action, COALESCE(target_user_id, 0), details
+FROM audit_log
+WHERE (target_user_id = $1 OR $1 = 0)
+ORDER BY id DESC
+LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, targetUserID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var details []byte
+		err := rows.Scan(
+			&totalRecords,
+			&entry.ID,
+			&entry.CreatedAt,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.TargetUserID,
+			&details,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		err = json.Unmarshal(details, &entry.Details)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		entries = append(entries, &entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return entries, metadata, nil
+}
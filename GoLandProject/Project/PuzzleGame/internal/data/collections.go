@@ -0,0 +1,441 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrDuplicateEntry is returned when a pack or item is added to a collection
+// that already holds it.
+var ErrDuplicateEntry = errors.New("duplicate collection entry")
+
+// Collection is a named, ordered list of packs and items put together by a
+// user. Public collections can be shared by their slug.
+type Collection struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Public      bool      `json:"public"`
+	Slug        string    `json:"slug"`
+	EntryCount  int       `json:"entry_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int32     `json:"version"`
+}
+
+// CollectionEntry is a pack in a collection, or a single item of it when
+// ItemID is set.
+type CollectionEntry struct {
+	ID          int64     `json:"id"`
+	Position    int       `json:"position"`
+	PuzzleID    int64     `json:"puzzle_id"`
+	PuzzleTitle string    `json:"puzzle_title"`
+	ItemID      *int64    `json:"item_id,omitempty"`
+	ItemTitle   string    `json:"item_title,omitempty"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(strings.TrimSpace(collection.Name) != "", "name", "validation.required")
+	v.Check(len(collection.Name) <= 100, "name", "validation.max_bytes", 100)
+	v.Check(len(collection.Description) <= 1000, "description", "validation.max_bytes", 1000)
+}
+
+// slugify turns a name into the readable part of a slug: lower case letters
+// and digits separated by single dashes, at most maxRunes of them in all.
+func slugify(name string, maxRunes int) string {
+	var b strings.Builder
+	dash := false
+	n := 0
+	for _, r := range strings.ToLower(name) {
+		if n == maxRunes {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				if n+2 > maxRunes {
+					break
+				}
+				b.WriteByte('-')
+				n++
+			}
+			b.WriteRune(r)
+			n++
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// collectionSlug combines the name of a collection with a random suffix, so
+// that slugs are unique without asking the owner to pick one and cannot be
+// guessed from the name alone.
+func collectionSlug(name string) (string, error) {
+	randomBytes := make([]byte, 5)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	suffix := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+	if base := slugify(name, 50); base != "" {
+		return base + "-" + suffix, nil
+	}
+	return "collection-" + suffix, nil
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func (m CollectionModel) Insert(collection *Collection) error {
+	slug, err := collectionSlug(collection.Name)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO collections (user_id, name, description, public, slug)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, slug, created_at, updated_at, version`
+	args := []interface{}{collection.UserID, collection.Name, collection.Description, collection.Public, slug}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&collection.ID,
+		&collection.Slug,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+		&collection.Version,
+	)
+}
+
+const collectionColumns = `
+		id, user_id, name, description, public, slug,
+		(SELECT count(*) FROM collection_entries WHERE collection_entries.collection_id = collections.id),
+		created_at, updated_at, version`
+
+func (m CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	return m.get(`WHERE id = $1`, id)
+}
+
+// GetBySlug returns the collection with the slug, private or not. As with
+// Get, it is up to the caller to hide private collections from other users.
+func (m CollectionModel) GetBySlug(slug string) (*Collection, error) {
+	return m.get(`WHERE slug = $1`, slug)
+}
+
+func (m CollectionModel) get(where string, arg interface{}) (*Collection, error) {
+	query := `SELECT ` + collectionColumns + ` FROM collections ` + where
+	var collection Collection
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, arg).Scan(
+		&collection.ID,
+		&collection.UserID,
+		&collection.Name,
+		&collection.Description,
+		&collection.Public,
+		&collection.Slug,
+		&collection.EntryCount,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+		&collection.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &collection, nil
+}
+
+// collectionSortColumns maps the sort keys accepted by the API onto columns
+// of the collections table.
+var collectionSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"updated_at": "updated_at",
+}
+
+// GetAllForUser lists the collections of a user, private ones included.
+func (m CollectionModel) GetAllForUser(userID int64, filters Filters) ([]*Collection, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM collections
+		WHERE user_id = $1
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, collectionColumns, collectionSortColumns[filters.sortColumn()], filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	collections := []*Collection{}
+	for rows.Next() {
+		var collection Collection
+		err := rows.Scan(
+			&totalRecords,
+			&collection.ID,
+			&collection.UserID,
+			&collection.Name,
+			&collection.Description,
+			&collection.Public,
+			&collection.Slug,
+			&collection.EntryCount,
+			&collection.CreatedAt,
+			&collection.UpdatedAt,
+			&collection.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		collections = append(collections, &collection)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return collections, metadata, nil
+}
+
+// Update saves the name, description and visibility of the collection if it
+// is still at the version it was read at and returns ErrEditConflict
+// otherwise. The slug stays the same, so links shared before the collection
+// was made private work again once it is public.
+func (m CollectionModel) Update(collection *Collection) error {
+	query := `
+		UPDATE collections
+		SET name = $1, description = $2, public = $3, updated_at = NOW(), version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version`
+	args := []interface{}{collection.Name, collection.Description, collection.Public, collection.ID, collection.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&collection.UpdatedAt, &collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m CollectionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM collections WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// entrySortColumns maps the sort keys accepted by the API onto columns of the
+// collection_entries table.
+var entrySortColumns = map[string]string{
+	"position": "collection_entries.position",
+	"added_at": "collection_entries.added_at",
+}
+
+// GetEntries lists the entries of a collection as seen by viewerID. Packs
+// that were trashed, or are not published and belong to someone else, are
+// left out while they stay that way.
+func (m CollectionModel) GetEntries(collectionID, viewerID int64, filters Filters) ([]*CollectionEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), collection_entries.id, collection_entries.position, collection_entries.puzzle_id,
+			puzzles.title, collection_entries.item_id, COALESCE(puzzle_items.title, ''), collection_entries.added_at
+		FROM collection_entries
+		INNER JOIN puzzles ON puzzles.id = collection_entries.puzzle_id
+		LEFT JOIN puzzle_items ON puzzle_items.id = collection_entries.item_id
+		WHERE collection_entries.collection_id = $1 AND puzzles.deleted_at IS NULL
+		AND (puzzles.status = 'published' OR puzzles.owner_id = $2)
+		ORDER BY %s %s, collection_entries.id ASC
+		LIMIT $3 OFFSET $4`, entrySortColumns[filters.sortColumn()], filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, collectionID, viewerID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	entries := []*CollectionEntry{}
+	for rows.Next() {
+		var entry CollectionEntry
+		err := rows.Scan(
+			&totalRecords,
+			&entry.ID,
+			&entry.Position,
+			&entry.PuzzleID,
+			&entry.PuzzleTitle,
+			&entry.ItemID,
+			&entry.ItemTitle,
+			&entry.AddedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		entries = append(entries, &entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return entries, metadata, nil
+}
+
+// AddEntry inserts the entry at its position, moving the entries from there
+// on down by one. A position of 0, or past the end, appends the entry.
+func (m CollectionModel) AddEntry(collection *Collection, entry *CollectionEntry) error {
+	return m.withEntries(collection, func(ctx context.Context, tx *sql.Tx, last int) error {
+		if entry.Position < 1 || entry.Position > last {
+			entry.Position = last + 1
+		} else {
+			_, err := tx.ExecContext(ctx, `
+				UPDATE collection_entries SET position = position + 1
+				WHERE collection_id = $1 AND position >= $2`, collection.ID, entry.Position)
+			if err != nil {
+				return err
+			}
+		}
+		query := `
+			INSERT INTO collection_entries (collection_id, puzzle_id, item_id, position)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, added_at`
+		err := tx.QueryRowContext(ctx, query, collection.ID, entry.PuzzleID, entry.ItemID, entry.Position).Scan(&entry.ID, &entry.AddedAt)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "collection_entries_target_idx"`:
+				return ErrDuplicateEntry
+			default:
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MoveEntry moves an entry to a new position, shifting the entries in
+// between by one. Positions past the end move it to the end.
+func (m CollectionModel) MoveEntry(collection *Collection, entryID int64, position int) error {
+	return m.withEntries(collection, func(ctx context.Context, tx *sql.Tx, last int) error {
+		var from int
+		err := tx.QueryRowContext(ctx, `
+			SELECT position FROM collection_entries
+			WHERE id = $1 AND collection_id = $2`, entryID, collection.ID).Scan(&from)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		if position > last {
+			position = last
+		}
+		query := `
+			UPDATE collection_entries
+			SET position = CASE
+				WHEN id = $2 THEN $4::integer
+				WHEN $4::integer < $3::integer THEN position + 1
+				ELSE position - 1
+			END
+			WHERE collection_id = $1 AND position BETWEEN LEAST($3::integer, $4::integer) AND GREATEST($3::integer, $4::integer)`
+		_, err = tx.ExecContext(ctx, query, collection.ID, entryID, from, position)
+		return err
+	})
+}
+
+// RemoveEntry deletes an entry and closes the gap it leaves.
+func (m CollectionModel) RemoveEntry(collection *Collection, entryID int64) error {
+	return m.withEntries(collection, func(ctx context.Context, tx *sql.Tx, last int) error {
+		var position int
+		err := tx.QueryRowContext(ctx, `
+			DELETE FROM collection_entries
+			WHERE id = $1 AND collection_id = $2
+			RETURNING position`, entryID, collection.ID).Scan(&position)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE collection_entries SET position = position - 1
+			WHERE collection_id = $1 AND position > $2`, collection.ID, position)
+		return err
+	})
+}
+
+// withEntries runs fn in a transaction holding the lock on the collection, so
+// that changes to its order are made one at a time. fn gets the position of
+// the last entry. Every change to the entries is a new version of the
+// collection.
+func (m CollectionModel) withEntries(collection *Collection, fn func(ctx context.Context, tx *sql.Tx, last int) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `
+		UPDATE collections
+		SET updated_at = NOW(), version = version + 1
+		WHERE id = $1
+		RETURNING updated_at, version`
+	err = tx.QueryRowContext(ctx, query, collection.ID).Scan(&collection.UpdatedAt, &collection.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	var last int
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(max(position), 0) FROM collection_entries WHERE collection_id = $1`, collection.ID).Scan(&last)
+	if err != nil {
+		return err
+	}
+	err = fn(ctx, tx, last)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,178 @@
+package data
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxRunes int
+		want     string
+	}{
+		{"Weekend Crosswords", 50, "weekend-crosswords"},
+		{"  Hard -- Sudoku!!  ", 50, "hard-sudoku"},
+		{"Судоку 2024", 50, "судоку-2024"},
+		{"Kakuro & Killer", 50, "kakuro-killer"},
+		{"!!!", 50, ""},
+		{"abcdef ghij", 8, "abcdef-g"},
+		// The limit counts the dashes, and a cut never leaves one trailing.
+		{"abc def", 4, "abc"},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.name, tt.maxRunes); got != tt.want {
+			t.Fatalf("slugify(%q, %d) = %q, want %q", tt.name, tt.maxRunes, got, tt.want)
+		}
+	}
+}
+
+func TestCollectionSlug(t *testing.T) {
+	suffix := `-[a-z2-7]{8}$`
+	tests := map[string]*regexp.Regexp{
+		"Weekend Crosswords":    regexp.MustCompile(`^weekend-crosswords` + suffix),
+		"???":                   regexp.MustCompile(`^collection` + suffix),
+		strings.Repeat("a", 80): regexp.MustCompile(`^a{50}` + suffix),
+	}
+	for name, pattern := range tests {
+		slug, err := collectionSlug(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pattern.MatchString(slug) {
+			t.Fatalf("collectionSlug(%q) = %q, want it to match %s", name, slug, pattern)
+		}
+	}
+	a, _ := collectionSlug("Same")
+	b, _ := collectionSlug("Same")
+	if a == b {
+		t.Fatalf("two collections with the same name got the slug %q", a)
+	}
+}
+
+// entryOrder returns the puzzles of a collection in entry order and checks
+// the positions run from 1 without gaps.
+func (f *dbFixture) entryOrder(collection *Collection) []int64 {
+	f.t.Helper()
+	rows, err := f.models.Collections.DB.Query(`
+		SELECT position, puzzle_id FROM collection_entries
+		WHERE collection_id = $1
+		ORDER BY position`, collection.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	defer rows.Close()
+	var puzzles []int64
+	for rows.Next() {
+		var position int
+		var puzzleID int64
+		if err := rows.Scan(&position, &puzzleID); err != nil {
+			f.t.Fatal(err)
+		}
+		if position != len(puzzles)+1 {
+			f.t.Fatalf("entry of puzzle %d is at position %d, want %d", puzzleID, position, len(puzzles)+1)
+		}
+		puzzles = append(puzzles, puzzleID)
+	}
+	if err := rows.Err(); err != nil {
+		f.t.Fatal(err)
+	}
+	return puzzles
+}
+
+func checkEntries(t *testing.T, step string, got []int64, want ...*Puzzle) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d entries, want %d", step, len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i].ID {
+			t.Fatalf("%s: position %d holds puzzle %d, want %d (%q)", step, i+1, got[i], want[i].ID, want[i].Title)
+		}
+	}
+}
+
+func TestEntryPositions(t *testing.T) {
+	f := newDBFixture(t, 1)
+	collection := &Collection{UserID: f.users[0], Name: f.word}
+	if err := f.models.Collections.Insert(collection); err != nil {
+		t.Fatal(err)
+	}
+	a, b, c, d := f.puzzle("a"), f.puzzle("b"), f.puzzle("c"), f.puzzle("d")
+	entries := map[int64]int64{}
+	add := func(puzzle *Puzzle, position int) {
+		t.Helper()
+		entry := &CollectionEntry{PuzzleID: puzzle.ID, Position: position}
+		if err := f.models.Collections.AddEntry(collection, entry); err != nil {
+			t.Fatal(err)
+		}
+		entries[puzzle.ID] = entry.ID
+	}
+
+	add(a, 0)
+	add(b, 0)
+	checkEntries(t, "append", f.entryOrder(collection), a, b)
+	add(c, 1)
+	checkEntries(t, "insert at the front", f.entryOrder(collection), c, a, b)
+	add(d, 9)
+	checkEntries(t, "insert past the end", f.entryOrder(collection), c, a, b, d)
+	err := f.models.Collections.AddEntry(collection, &CollectionEntry{PuzzleID: a.ID, Position: 1})
+	if !errors.Is(err, ErrDuplicateEntry) {
+		t.Fatalf("expected ErrDuplicateEntry, got %v", err)
+	}
+	checkEntries(t, "duplicate", f.entryOrder(collection), c, a, b, d)
+
+	move := func(puzzle *Puzzle, position int) {
+		t.Helper()
+		if err := f.models.Collections.MoveEntry(collection, entries[puzzle.ID], position); err != nil {
+			t.Fatal(err)
+		}
+	}
+	move(c, 3)
+	checkEntries(t, "move down", f.entryOrder(collection), a, b, c, d)
+	move(d, 1)
+	checkEntries(t, "move up", f.entryOrder(collection), d, a, b, c)
+	move(a, 9)
+	checkEntries(t, "move past the end", f.entryOrder(collection), d, b, c, a)
+	move(b, 2)
+	checkEntries(t, "move in place", f.entryOrder(collection), d, b, c, a)
+
+	if err := f.models.Collections.RemoveEntry(collection, entries[b.ID]); err != nil {
+		t.Fatal(err)
+	}
+	checkEntries(t, "remove", f.entryOrder(collection), d, c, a)
+	if err := f.models.Collections.RemoveEntry(collection, entries[b.ID]); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// Every change is a new version; the failed ones roll theirs back.
+	saved, err := f.models.Collections.Get(collection.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != 10 || saved.EntryCount != 3 {
+		t.Fatalf("got version %d with %d entries, want version 10 with 3", saved.Version, saved.EntryCount)
+	}
+}
+
+func TestGetBySlug(t *testing.T) {
+	f := newDBFixture(t, 1)
+	collection := &Collection{UserID: f.users[0], Name: f.word}
+	if err := f.models.Collections.Insert(collection); err != nil {
+		t.Fatal(err)
+	}
+	// Private collections are found too; readCollection hides them from
+	// everybody but the owner.
+	got, err := f.models.Collections.GetBySlug(collection.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != collection.ID || got.Public {
+		t.Fatalf("got collection %d (public %v), want private collection %d", got.ID, got.Public, collection.ID)
+	}
+	if _, err := f.models.Collections.GetBySlug(collection.Slug + "x"); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+}
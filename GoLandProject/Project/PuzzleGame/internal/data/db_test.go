@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// openTestDB connects to the migrated database named by $PUZZLE_TEST_DSN.
+// Tests that need one are skipped when it is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("PUZZLE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("PUZZLE_TEST_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// dbFixture creates throwaway users, and puzzles titled after a word unique
+// to the test, and removes them again afterwards along with everything that
+// refers to them.
+type dbFixture struct {
+	t      *testing.T
+	models Models
+	word   string
+	users  []int64
+}
+
+func newDBFixture(t *testing.T, users int) *dbFixture {
+	db := openTestDB(t)
+	f := &dbFixture{t: t, models: NewModels(db), word: fmt.Sprintf("datatest%d", time.Now().UnixNano())}
+	for i := 0; i < users; i++ {
+		var id int64
+		err := db.QueryRow(`
+			INSERT INTO users (name, email, password_hash, activated)
+			VALUES ($1, $2, '\x00', true)
+			RETURNING id`, f.word, fmt.Sprintf("%s-%d@example.com", f.word, i)).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.users = append(f.users, id)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM puzzles WHERE title LIKE $1 || '%'`, f.word)
+		db.Exec(`DELETE FROM users WHERE name = $1`, f.word)
+	})
+	return f
+}
+
+// puzzle creates a puzzle rated once by each of the given ratings.
+func (f *dbFixture) puzzle(name string, ratings ...int) *Puzzle {
+	f.t.Helper()
+	puzzle := &Puzzle{Title: f.word + " " + name, NumOfPuzzles: 1, Genres: []string{"logic"}, OwnerID: f.users[0]}
+	if err := f.models.Puzzles.Insert(puzzle); err != nil {
+		f.t.Fatal(err)
+	}
+	for i, rating := range ratings {
+		if err := f.models.Reviews.Insert(&Review{PuzzleID: puzzle.ID, UserID: f.users[i], Rating: rating}); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+	return f.get(puzzle.ID)
+}
+
+func (f *dbFixture) get(id int64) *Puzzle {
+	f.t.Helper()
+	puzzle, err := f.models.Puzzles.Get(id)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return puzzle
+}
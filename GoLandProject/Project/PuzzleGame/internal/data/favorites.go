@@ -0,0 +1,115 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Favorite marks a puzzle a user wants to find again quickly.
+type Favorite struct {
+	PuzzleID  int64     `json:"puzzle_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FavoriteModel struct {
+	DB *sql.DB
+}
+
+// Add marks the puzzle as a favorite of the user. Adding it twice keeps the
+// time it was first added.
+func (m FavoriteModel) Add(userID, puzzleID int64) (*Favorite, error) {
+	query := `
+		INSERT INTO favorites (user_id, puzzle_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, puzzle_id) DO UPDATE SET created_at = favorites.created_at
+		RETURNING created_at`
+	favorite := &Favorite{PuzzleID: puzzleID}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, userID, puzzleID).Scan(&favorite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return favorite, nil
+}
+
+func (m FavoriteModel) Remove(userID, puzzleID int64) error {
+	query := `
+		DELETE FROM favorites
+		WHERE user_id = $1 AND puzzle_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, userID, puzzleID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// favoriteSortColumns maps the sort keys accepted by the API onto columns of
+// the favorites query.
+var favoriteSortColumns = map[string]string{
+	"favorited_at": "favorites.created_at",
+	"title":        "puzzles.title",
+}
+
+// GetAll lists the user's favorite puzzles. Puzzles that were trashed, or
+// are no longer published and belong to someone else, are left out until
+// they come back.
+func (m FavoriteModel) GetAll(userID int64, filters Filters) ([]*Puzzle, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), puzzles.id, puzzles.created_at, puzzles.title, puzzles.numOfPuzzles, puzzles.genres,
+			COALESCE(puzzles.owner_id, 0), puzzles.status, puzzles.thumbnail, puzzles.rating_avg, puzzles.rating_count, puzzles.version
+		FROM favorites
+		INNER JOIN puzzles ON puzzles.id = favorites.puzzle_id
+		WHERE favorites.user_id = $1 AND puzzles.deleted_at IS NULL
+		AND (puzzles.status = 'published' OR puzzles.owner_id = $1)
+		ORDER BY %s %s, puzzles.id ASC
+		LIMIT $2 OFFSET $3`, favoriteSortColumns[filters.sortColumn()], filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	puzzles := []*Puzzle{}
+	for rows.Next() {
+		var puzzle Puzzle
+		err := rows.Scan(
+			&totalRecords,
+			&puzzle.ID,
+			&puzzle.CreatedAt,
+			&puzzle.Title,
+			&puzzle.NumOfPuzzles,
+			pq.Array(&puzzle.Genres),
+			&puzzle.OwnerID,
+			&puzzle.Status,
+			&puzzle.Thumbnail,
+			&puzzle.Rating,
+			&puzzle.RatingCount,
+			&puzzle.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		puzzle.setThumbnailURL()
+		puzzles = append(puzzles, &puzzle)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return puzzles, metadata, nil
+}
@@ -2,15 +2,47 @@ package data
 
 import (
 	"Puzzle.Ayan.net/internal/validator"
+	"encoding/base64"
+	"encoding/json"
 	"math"
 	"strings"
 )
 
+// Filters holds the pagination and sorting options of a listing. Listings are
+// paged by Page and PageSize unless UseCursor is set, in which case Cursor
+// (empty for the first page) is used for keyset pagination instead.
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	Cursor       string
+	UseCursor    bool
+}
+
+// cursor is the decoded form of the opaque cursor handed out to clients. It
+// records the sort key and id of the row at the edge of the page so the next
+// query can continue from it, and whether it points backwards.
+type cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int64  `json:"i"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(js, &c)
+	return c, err
 }
 
 func (f Filters) sortColumn() string {
@@ -35,19 +67,27 @@ func (f Filters) offset() int {
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
-	v.Check(f.Page > 0, "page", "must be greater than zero")
-	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
-	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
-	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
-	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	v.Check(f.Page > 0, "page", "validation.greater_than_zero")
+	v.Check(f.Page <= 10_000_000, "page", "validation.max_page")
+	v.Check(f.PageSize > 0, "page_size", "validation.greater_than_zero")
+	v.Check(f.PageSize <= 100, "page_size", "validation.max_value", 100)
+	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "validation.sort")
+	v.Check(!f.UseCursor || f.Page == 1, "page", "validation.cursor_with_page")
+	if f.UseCursor && f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "validation.cursor")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "validation.cursor_sort")
+	}
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -0,0 +1,315 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrDuplicateGenre is returned when the slug of a genre is already the
+	// slug or an alias of another genre.
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	// ErrDuplicateGenreAlias is returned when an alias of a genre is already
+	// the slug or an alias of another genre.
+	ErrDuplicateGenreAlias = errors.New("duplicate genre alias")
+)
+
+// Genre is one entry of the managed list puzzles are filed under. Puzzles
+// store the slug; the aliases are other spellings accepted from clients.
+type Genre struct {
+	ID          int64    `json:"id"`
+	Slug        string   `json:"slug"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Aliases     []string `json:"aliases"`
+	PuzzleCount int      `json:"puzzle_count"`
+	Version     int32    `json:"version"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Slug != "", "slug", "validation.required")
+	v.Check(len(genre.Slug) <= 50, "slug", "validation.max_bytes", 50)
+	v.Check(genre.Slug == slugify(genre.Slug, 50), "slug", "validation.slug")
+	v.Check(strings.TrimSpace(genre.Name) != "", "name", "validation.required")
+	v.Check(len(genre.Name) <= 100, "name", "validation.max_bytes", 100)
+	v.Check(len(genre.Description) <= 1000, "description", "validation.max_bytes", 1000)
+	v.Check(len(genre.Aliases) <= 20, "aliases", "validation.max_value", 20)
+	v.Check(validator.Unique(genre.Aliases), "aliases", "validation.duplicates")
+	for _, alias := range genre.Aliases {
+		v.Check(alias != "", "aliases", "validation.required")
+		v.Check(alias != genre.Slug, "aliases", "validation.duplicates")
+	}
+}
+
+// normalizeGenre is the form genre names and aliases are compared in.
+func normalizeGenre(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeAliases brings aliases into the form they are stored in.
+func NormalizeAliases(aliases []string) []string {
+	normalized := make([]string, len(aliases))
+	for i, alias := range aliases {
+		normalized[i] = normalizeGenre(alias)
+	}
+	return normalized
+}
+
+// genreSlug folds a free-text genre onto a slug the way the genre_slug
+// function of migration 000022 did when the genres in use were first turned
+// into slugs: slugified, with a trailing "puzzle" or "puzzles" dropped. The
+// two must agree, or spellings that were folded then would not resolve now.
+func genreSlug(name string) string {
+	slug := slugify(name, 100)
+	for _, suffix := range []string{"-puzzles", "-puzzle"} {
+		if trimmed := strings.TrimSuffix(slug, suffix); trimmed != slug {
+			return trimmed
+		}
+	}
+	return slug
+}
+
+// GenreSet maps every accepted spelling of a genre to its slug.
+type GenreSet map[string]string
+
+// newGenreSet builds the set for the given genres. Slugs win over aliases,
+// and aliases over names, should two genres claim the same spelling.
+func newGenreSet(genres []*Genre) GenreSet {
+	set := make(GenreSet)
+	for _, genre := range genres {
+		set[normalizeGenre(genre.Name)] = genre.Slug
+	}
+	for _, genre := range genres {
+		for _, alias := range genre.Aliases {
+			set[alias] = genre.Slug
+		}
+	}
+	for _, genre := range genres {
+		set[genre.Slug] = genre.Slug
+	}
+	return set
+}
+
+// lookup finds the genre of name. A slug may itself end in "-puzzle", so the
+// plain slugified form is tried before the folded one.
+func (s GenreSet) lookup(name string) (string, bool) {
+	for _, key := range []string{normalizeGenre(name), slugify(name, 100), genreSlug(name)} {
+		if slug, ok := s[key]; ok {
+			return slug, true
+		}
+	}
+	return "", false
+}
+
+// ResolveGenres returns genres with every slug, name or alias replaced by
+// the slug of its genre, and records an error under key for those that are
+// not in the set. Unknown genres are returned unchanged.
+func ResolveGenres(v *validator.Validator, key string, genres []string, known GenreSet) []string {
+	if genres == nil {
+		return nil
+	}
+	resolved := make([]string, len(genres))
+	var unknown []string
+	for i, genre := range genres {
+		slug, ok := known.lookup(genre)
+		if !ok {
+			unknown = append(unknown, genre)
+			slug = genre
+		}
+		resolved[i] = slug
+	}
+	v.Check(len(unknown) == 0, key, "validation.unknown_genres", strings.Join(unknown, ", "))
+	return resolved
+}
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+// GetAll lists the genres by name, each with the number of published puzzles
+// filed under it.
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+		SELECT genres.id, genres.slug, genres.name, genres.description, genres.aliases, count(puzzles.id), genres.version
+		FROM genres
+		LEFT JOIN puzzles ON puzzles.genres @> ARRAY[genres.slug]
+			AND puzzles.status = 'published' AND puzzles.deleted_at IS NULL
+		GROUP BY genres.id
+		ORDER BY genres.name, genres.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	genres := []*Genre{}
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(
+			&genre.ID,
+			&genre.Slug,
+			&genre.Name,
+			&genre.Description,
+			pq.Array(&genre.Aliases),
+			&genre.PuzzleCount,
+			&genre.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// Set loads every accepted spelling of every genre.
+func (m GenreModel) Set() (GenreSet, error) {
+	query := `SELECT slug, name, aliases FROM genres`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var genres []*Genre
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(&genre.Slug, &genre.Name, pq.Array(&genre.Aliases))
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return newGenreSet(genres), nil
+}
+
+func (m GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT id, slug, name, description, aliases, version
+		FROM genres
+		WHERE id = $1`
+	var genre Genre
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.Slug,
+		&genre.Name,
+		&genre.Description,
+		pq.Array(&genre.Aliases),
+		&genre.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &genre, nil
+}
+
+func (m GenreModel) Insert(genre *Genre) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = checkGenreSpellings(ctx, tx, genre)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO genres (slug, name, description, aliases)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, version`
+	args := []interface{}{genre.Slug, genre.Name, genre.Description, pq.Array(genre.Aliases)}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.ID, &genre.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "genres_slug_key"`:
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Update saves the name, description and aliases of the genre. The slug is
+// what puzzles refer to, so it never changes.
+func (m GenreModel) Update(genre *Genre) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = checkGenreSpellings(ctx, tx, genre)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE genres
+		SET name = $1, description = $2, aliases = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version`
+	args := []interface{}{genre.Name, genre.Description, pq.Array(genre.Aliases), genre.ID, genre.Version}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// checkGenreSpellings returns ErrDuplicateGenre when the slug of the genre,
+// and ErrDuplicateGenreAlias when one of its aliases, is the slug or an alias
+// of another one. The table is locked for the rest of the transaction so that
+// two genres cannot claim the same alias at once.
+func checkGenreSpellings(ctx context.Context, tx *sql.Tx, genre *Genre) error {
+	_, err := tx.ExecContext(ctx, `LOCK TABLE genres IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		return err
+	}
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM genres WHERE id <> $1 AND (slug = $2 OR $2 = ANY(aliases))),
+			EXISTS (SELECT 1 FROM genres WHERE id <> $1 AND (slug = ANY($3) OR aliases && $3))`
+	var slugTaken, aliasTaken bool
+	err = tx.QueryRowContext(ctx, query, genre.ID, genre.Slug, pq.Array(genre.Aliases)).Scan(&slugTaken, &aliasTaken)
+	if err != nil {
+		return err
+	}
+	switch {
+	case slugTaken:
+		return ErrDuplicateGenre
+	case aliasTaken:
+		return ErrDuplicateGenreAlias
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGenreSlug(t *testing.T) {
+	tests := map[string]string{
+		"Logic":              "logic",
+		"logic-puzzles":      "logic",
+		"Logic Puzzle":       "logic",
+		"  Word  Search!! ":  "word-search",
+		"Jigsaw_Puzzles":     "jigsaw",
+		"puzzles":            "puzzles",
+		"puzzle-hunt":        "puzzle-hunt",
+		"Судоку":             "судоку",
+		"nonograms-puzzles2": "nonograms-puzzles2",
+	}
+	for name, want := range tests {
+		if got := genreSlug(name); got != want {
+			t.Fatalf("genreSlug(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNewGenreSetPrecedence(t *testing.T) {
+	set := newGenreSet([]*Genre{
+		{Slug: "crossword", Name: "Crossword", Aliases: []string{"cryptic"}},
+		// The name of one genre is the alias of another and the slug of a
+		// third: the slug wins, then the alias.
+		{Slug: "cryptic", Name: "Crossword Puzzle"},
+		{Slug: "word-search", Name: "Cryptic", Aliases: []string{"crossword puzzle"}},
+	})
+	tests := map[string]string{
+		"crossword":        "crossword",
+		"cryptic":          "cryptic",
+		"crossword puzzle": "word-search",
+		"word-search":      "word-search",
+	}
+	for spelling, want := range tests {
+		if got := set[spelling]; got != want {
+			t.Fatalf("set[%q] = %q, want %q", spelling, got, want)
+		}
+	}
+}
+
+func TestResolveGenres(t *testing.T) {
+	set := newGenreSet([]*Genre{
+		{Slug: "logic", Name: "Logic Puzzles", Aliases: []string{"brain teasers"}},
+		{Slug: "word-search", Name: "Word Search"},
+		{Slug: "jigsaw-puzzle", Name: "Jigsaw"},
+	})
+
+	v := validator.New()
+	got := ResolveGenres(v, "genres", []string{"Logic", "  BRAIN TEASERS ", "word search", "Word-Search-Puzzles", "Jigsaw Puzzle", "jigsaw"}, set)
+	want := []string{"logic", "logic", "word-search", "word-search", "jigsaw-puzzle", "jigsaw-puzzle"}
+	if !v.Valid() {
+		t.Fatalf("unexpected errors %v", v.Errors)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	v = validator.New()
+	got = ResolveGenres(v, "genres", []string{"logic", "Chess", "kakuro"}, set)
+	if want := []string{"logic", "Chess", "kakuro"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if _, ok := v.Errors["genres"]; !ok {
+		t.Fatalf("unknown genres were not reported: %v", v.Errors)
+	}
+
+	if got := ResolveGenres(validator.New(), "genres", nil, set); got != nil {
+		t.Fatalf("got %q for no genres, want nil", got)
+	}
+}
+
+func TestGenreModelSet(t *testing.T) {
+	f := newDBFixture(t, 0)
+	db := f.models.Genres.DB
+	t.Cleanup(func() { db.Exec(`DELETE FROM genres WHERE slug LIKE $1 || '%'`, f.word) })
+
+	first := &Genre{Slug: f.word + "-a", Name: f.word + " Shared", Aliases: []string{f.word + " alias"}}
+	if err := f.models.Genres.Insert(first); err != nil {
+		t.Fatal(err)
+	}
+	clash := &Genre{Slug: f.word + "-b", Name: "B", Aliases: []string{f.word + " alias"}}
+	if err := f.models.Genres.Insert(clash); !errors.Is(err, ErrDuplicateGenreAlias) {
+		t.Fatalf("expected ErrDuplicateGenreAlias, got %v", err)
+	}
+	clash = &Genre{Slug: f.word + "-a", Name: "A again"}
+	if err := f.models.Genres.Insert(clash); !errors.Is(err, ErrDuplicateGenre) {
+		t.Fatalf("expected ErrDuplicateGenre, got %v", err)
+	}
+	// Names are not checked for clashes, so the set has to settle them.
+	second := &Genre{Slug: f.word + "-b", Name: f.word + " Shared"}
+	if err := f.models.Genres.Insert(second); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := f.models.Genres.Set()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slug, ok := set.lookup(f.word + " alias"); !ok || slug != first.Slug {
+		t.Fatalf("alias resolved to %q, want %q", slug, first.Slug)
+	}
+	if slug, ok := set.lookup(first.Slug + " puzzles"); !ok || slug != first.Slug {
+		t.Fatalf("slug with a suffix resolved to %q, want %q", slug, first.Slug)
+	}
+	if slug, ok := set.lookup(f.word + " shared"); !ok || (slug != first.Slug && slug != second.Slug) {
+		t.Fatalf("shared name resolved to %q", slug)
+	}
+}
@@ -0,0 +1,139 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PuzzleItem is one playable puzzle inside a pack, such as a single crossword
+// or sudoku. Content holds the grid in a layout that depends on Kind.
+type PuzzleItem struct {
+	ID           int64           `json:"id"`
+	PuzzleID     int64           `json:"puzzle_id"`
+	Position     int             `json:"position"`
+	Kind         string          `json:"kind"`
+	Title        string          `json:"title"`
+	Content      json.RawMessage `json:"content,omitempty"`
+	Thumbnail    string          `json:"-"`
+	ThumbnailURL string          `json:"thumbnail_url,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+func (item *PuzzleItem) setThumbnailURL() {
+	if item.Thumbnail != "" {
+		item.ThumbnailURL = fmt.Sprintf("/v1/puzzles/%d/items/%d/thumbnail", item.PuzzleID, item.ID)
+	}
+}
+
+func (b *PuzzleBatch) InsertItem(item *PuzzleItem) error {
+	query := `
+		INSERT INTO puzzle_items (puzzle_id, position, kind, title, content)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+	args := []interface{}{item.PuzzleID, item.Position, item.Kind, item.Title, []byte(item.Content)}
+	return b.tx.QueryRowContext(b.ctx, query, args...).Scan(&item.ID, &item.CreatedAt)
+}
+
+type ItemModel struct {
+	DB *sql.DB
+}
+
+func (m ItemModel) Get(puzzleID, itemID int64) (*PuzzleItem, error) {
+	if puzzleID < 1 || itemID < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT id, puzzle_id, position, kind, title, content, thumbnail, created_at
+		FROM puzzle_items
+		WHERE puzzle_id = $1 AND id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var item PuzzleItem
+	// Scanning into a *[]byte copies the value out of the driver's buffer,
+	// which scanning straight into a json.RawMessage would not.
+	var content []byte
+	err := m.DB.QueryRowContext(ctx, query, puzzleID, itemID).Scan(
+		&item.ID,
+		&item.PuzzleID,
+		&item.Position,
+		&item.Kind,
+		&item.Title,
+		&content,
+		&item.Thumbnail,
+		&item.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	item.Content = content
+	item.setThumbnailURL()
+	return &item, nil
+}
+
+// GetAll returns the items of a pack in order, without their content, which
+// can be large and is fetched one item at a time.
+func (m ItemModel) GetAll(puzzleID int64) ([]*PuzzleItem, error) {
+	query := `
+		SELECT id, puzzle_id, position, kind, title, thumbnail, created_at
+		FROM puzzle_items
+		WHERE puzzle_id = $1
+		ORDER BY position`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, puzzleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*PuzzleItem{}
+	for rows.Next() {
+		var item PuzzleItem
+		err := rows.Scan(
+			&item.ID,
+			&item.PuzzleID,
+			&item.Position,
+			&item.Kind,
+			&item.Title,
+			&item.Thumbnail,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.setThumbnailURL()
+		items = append(items, &item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// SetThumbnail records the blob key of the preview of an item. The preview of
+// the first item in a pack doubles as the preview of the pack. The pack keeps
+// its version, since the preview is not an edit and has no revision; the key
+// is part of the tag the API hands out instead.
+func (m ItemModel) SetThumbnail(itemID int64, key string) error {
+	query := `
+		WITH item AS (
+			UPDATE puzzle_items SET thumbnail = $2
+			WHERE id = $1
+			RETURNING puzzle_id, position
+		)
+		UPDATE puzzles SET thumbnail = $2
+		FROM item
+		WHERE puzzles.id = item.puzzle_id AND item.position = 1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, itemID, key)
+	return err
+}
@@ -0,0 +1,299 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+var JobStatuses = []string{JobPending, JobRunning, JobSucceeded, JobDead}
+
+// ErrJobNotRetryable is returned when retrying a job that has not failed.
+var ErrJobNotRetryable = errors.New("job is not dead")
+
+// Job is one unit of background work. A job is claimed by a single worker at
+// a time; when it fails it goes back to pending with a later RunAt until it
+// has used up MaxAttempts, after which it is dead and waits for an admin.
+type Job struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, so that a row can be
+// written in the same transaction as the change that calls for it.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryer is the counterpart of execer for statements that return a row.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type JobModel struct {
+	DB *sql.DB
+}
+
+// Enqueue queues a job of the given kind to run as soon as a worker is free.
+// The payload is stored as JSON.
+func (m JobModel) Enqueue(kind string, payload interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return enqueueJob(ctx, m.DB, kind, payload)
+}
+
+func enqueueJob(ctx context.Context, db execer, kind string, payload interface{}) error {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO jobs (kind, payload) VALUES ($1, $2)`, kind, js)
+	return err
+}
+
+// Enqueue queues a job as part of the batch. The job only becomes visible to
+// the workers once the batch is committed, and is dropped with it on rollback.
+func (b *PuzzleBatch) Enqueue(kind string, payload interface{}) error {
+	return enqueueJob(b.ctx, b.tx, kind, payload)
+}
+
+// Claim takes the next job that is due and marks it as running. Concurrent
+// workers skip rows locked by each other, so a job is never handed out twice.
+// It returns ErrRecordNotFound when no job is due.
+func (m JobModel) Claim() (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'pending' AND run_at <= NOW()
+			ORDER BY run_at, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, kind, payload, status, attempts, max_attempts, run_at, last_error, created_at, updated_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	job, err := scanJob(m.DB.QueryRowContext(ctx, query))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+func (m JobModel) Complete(job *Job) error {
+	query := `
+		UPDATE jobs
+		SET status = 'succeeded', locked_at = NULL, last_error = '', updated_at = NOW()
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, job.ID)
+	return err
+}
+
+// Fail records a failed attempt. The job is retried at retryAt, or becomes
+// dead once it has no attempts left.
+func (m JobModel) Fail(job *Job, jobErr error, retryAt time.Time) error {
+	query := `
+		UPDATE jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+			run_at = $2, locked_at = NULL, last_error = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING status`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return m.DB.QueryRowContext(ctx, query, job.ID, retryAt, jobErr.Error()).Scan(&job.Status)
+}
+
+// Kill marks a job as dead straight away, for failures that retrying cannot
+// fix.
+func (m JobModel) Kill(job *Job, jobErr error) error {
+	query := `
+		UPDATE jobs
+		SET status = 'dead', locked_at = NULL, last_error = $2, updated_at = NOW()
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, job.ID, jobErr.Error())
+	job.Status = JobDead
+	return err
+}
+
+// ReleaseStale puts jobs that have been running since before lockedBefore
+// back in the queue. A job is only left running that long when the worker
+// handling it died, for example because the server crashed.
+func (m JobModel) ReleaseStale(lockedBefore time.Time) (int64, error) {
+	query := `
+		UPDATE jobs
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+			locked_at = NULL, last_error = 'worker stopped while running the job', updated_at = NOW()
+		WHERE status = 'running' AND locked_at < $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, lockedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Retry gives a dead job a fresh set of attempts.
+func (m JobModel) Retry(id int64) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', attempts = 0, run_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING id, kind, payload, status, attempts, max_attempts, run_at, last_error, created_at, updated_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	job, err := scanJob(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			_, err := m.Get(id)
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrJobNotRetryable
+		default:
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// DeleteSucceeded removes jobs that succeeded before the given time. Dead
+// jobs are kept until an admin has looked at them.
+func (m JobModel) DeleteSucceeded(before time.Time) (int64, error) {
+	query := `
+		DELETE FROM jobs
+		WHERE status = 'succeeded' AND updated_at < $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m JobModel) Get(id int64) (*Job, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT id, kind, payload, status, attempts, max_attempts, run_at, last_error, created_at, updated_at
+		FROM jobs
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	job, err := scanJob(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// GetAll lists jobs, newest first. Empty status or kind match everything.
+func (m JobModel) GetAll(status, kind string, filters Filters) ([]*Job, Metadata, error) {
+	query := `
+		SELECT count(*) OVER(), id, kind, payload, status, attempts, max_attempts, run_at, last_error, created_at, updated_at
+		FROM jobs
+		WHERE (status = $1 OR $1 = '')
+		AND (kind = $2 OR $2 = '')
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, status, kind, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	jobs := []*Job{}
+	for rows.Next() {
+		var job Job
+		var payload []byte
+		err := rows.Scan(
+			&totalRecords,
+			&job.ID,
+			&job.Kind,
+			&payload,
+			&job.Status,
+			&job.Attempts,
+			&job.MaxAttempts,
+			&job.RunAt,
+			&job.LastError,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		job.Payload = payload
+		jobs = append(jobs, &job)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return jobs, metadata, nil
+}
+
+// scanJob reads the payload through a *[]byte, which unlike a
+// json.RawMessage makes database/sql copy it out of the driver's buffer.
+func scanJob(row *sql.Row) (*Job, error) {
+	var job Job
+	var payload []byte
+	err := row.Scan(
+		&job.ID,
+		&job.Kind,
+		&payload,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.RunAt,
+		&job.LastError,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = payload
+	return &job, nil
+}
+
+func ValidateJobFilters(v *validator.Validator, status string) {
+	v.Check(status == "" || validator.In(status, JobStatuses...), "status", "validation.job_status")
+}
@@ -11,17 +11,41 @@ var (
 )
 
 type Models struct {
-	Puzzles     PuzzleModel
-	Permissions PermissionModel
-	Tokens      TokenModel
-	Users       UserModel
+	Audit         AuditModel
+	Collections   CollectionModel
+	Favorites     FavoriteModel
+	Genres        GenreModel
+	Items         ItemModel
+	Jobs          JobModel
+	Notifications NotificationModel
+	Outbox        OutboxModel
+	Puzzles       PuzzleModel
+	Permissions   PermissionModel
+	Reviews       ReviewModel
+	Revisions     RevisionModel
+	Roles         RoleModel
+	Tasks         TaskModel
+	Tokens        TokenModel
+	Users         UserModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Puzzles:     PuzzleModel{DB: db},
-		Permissions: PermissionModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Users:       UserModel{DB: db},
+		Audit:         AuditModel{DB: db},
+		Collections:   CollectionModel{DB: db},
+		Favorites:     FavoriteModel{DB: db},
+		Genres:        GenreModel{DB: db},
+		Items:         ItemModel{DB: db},
+		Jobs:          JobModel{DB: db},
+		Notifications: NotificationModel{DB: db},
+		Outbox:        OutboxModel{DB: db},
+		Puzzles:       PuzzleModel{DB: db},
+		Permissions:   PermissionModel{DB: db},
+		Reviews:       ReviewModel{DB: db},
+		Revisions:     RevisionModel{DB: db},
+		Roles:         RoleModel{DB: db},
+		Tasks:         TaskModel{DB: db},
+		Tokens:        TokenModel{DB: db},
+		Users:         UserModel{DB: db},
 	}
 }
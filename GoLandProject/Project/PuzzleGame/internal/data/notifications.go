@@ -0,0 +1,291 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Notification kinds. Each is a separate email a user can opt in to, and the
+// unit an unsubscribe link turns off.
+const (
+	NotifyDailyReminder = "daily_reminder"
+	NotifyWeeklyDigest  = "weekly_digest"
+	NotifyNewPacks      = "new_packs"
+)
+
+var NotificationKinds = []string{NotifyDailyReminder, NotifyWeeklyDigest, NotifyNewPacks}
+
+// NotificationSettings are the emails a user has opted in to. Users without a
+// row have every notification turned off.
+type NotificationSettings struct {
+	UserID         int64    `json:"-"`
+	DailyReminder  bool     `json:"daily_reminder"`
+	WeeklyDigest   bool     `json:"weekly_digest"`
+	NewPacks       bool     `json:"new_packs"`
+	FollowedGenres []string `json:"followed_genres"`
+}
+
+func ValidateNotificationSettings(v *validator.Validator, settings *NotificationSettings) {
+	v.Check(len(settings.FollowedGenres) <= 20, "followed_genres", "validation.max_genres", 20)
+	v.Check(validator.Unique(settings.FollowedGenres), "followed_genres", "validation.duplicates")
+	for _, genre := range settings.FollowedGenres {
+		v.Check(genre != "", "followed_genres", "validation.required")
+	}
+}
+
+// Subscriber is a user due to receive a notification.
+type Subscriber struct {
+	UserID         int64
+	Name           string
+	Email          string
+	Locale         string
+	FollowedGenres []string
+	LastSentAt     *time.Time
+}
+
+// PuzzleSummary is what a notification shows of a puzzle.
+type PuzzleSummary struct {
+	ID     int64    `json:"id"`
+	Title  string   `json:"title"`
+	Genres []string `json:"genres"`
+}
+
+// WeeklyStats summarises a week for the digest.
+type WeeklyStats struct {
+	Published    int `json:"published"`
+	OwnTotal     int `json:"own_total"`
+	OwnPublished int `json:"own_published"`
+	OwnCreated   int `json:"own_created"`
+}
+
+// notificationColumns maps a kind to its opt-in and last-sent columns. Only
+// these names are ever formatted into a query.
+var notificationColumns = map[string][2]string{
+	NotifyDailyReminder: {"daily_reminder", "daily_reminder_sent_at"},
+	NotifyWeeklyDigest:  {"weekly_digest", "weekly_digest_sent_at"},
+	NotifyNewPacks:      {"new_packs", "new_packs_sent_at"},
+}
+
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+func (m NotificationModel) Get(userID int64) (*NotificationSettings, error) {
+	query := `
+		SELECT daily_reminder, weekly_digest, new_packs, followed_genres
+		FROM notification_settings
+		WHERE user_id = $1`
+	settings := &NotificationSettings{UserID: userID, FollowedGenres: []string{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&settings.DailyReminder,
+		&settings.WeeklyDigest,
+		&settings.NewPacks,
+		pq.Array(&settings.FollowedGenres),
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Save stores the settings, creating the row on first use.
+func (m NotificationModel) Save(settings *NotificationSettings) error {
+	query := `
+		INSERT INTO notification_settings (user_id, daily_reminder, weekly_digest, new_packs, followed_genres)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET daily_reminder = EXCLUDED.daily_reminder, weekly_digest = EXCLUDED.weekly_digest,
+			new_packs = EXCLUDED.new_packs, followed_genres = EXCLUDED.followed_genres, updated_at = NOW()`
+	args := []interface{}{
+		settings.UserID,
+		settings.DailyReminder,
+		settings.WeeklyDigest,
+		settings.NewPacks,
+		pq.Array(settings.FollowedGenres),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Unsubscribe turns off one kind of notification. Users without settings have
+// nothing to turn off.
+func (m NotificationModel) Unsubscribe(userID int64, kind string) error {
+	query := `
+		UPDATE notification_settings
+		SET daily_reminder = daily_reminder AND $2 <> 'daily_reminder',
+			weekly_digest = weekly_digest AND $2 <> 'weekly_digest',
+			new_packs = new_packs AND $2 <> 'new_packs',
+			updated_at = NOW()
+		WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, userID, kind)
+	return err
+}
+
+// Due lists activated users who want kind and have not been sent it since
+// sentBefore, those waiting longest first.
+func (m NotificationModel) Due(kind string, sentBefore time.Time, limit int) ([]*Subscriber, error) {
+	columns, ok := notificationColumns[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown notification kind %q", kind)
+	}
+	query := fmt.Sprintf(`
+		SELECT users.id, users.name, users.email, users.locale, notification_settings.followed_genres, notification_settings.%[2]s
+		FROM notification_settings
+		INNER JOIN users ON users.id = notification_settings.user_id
+		WHERE notification_settings.%[1]s AND users.activated
+		AND (notification_settings.%[2]s IS NULL OR notification_settings.%[2]s < $1)
+		ORDER BY notification_settings.%[2]s NULLS FIRST, users.id
+		LIMIT $2`, columns[0], columns[1])
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, sentBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subscribers := []*Subscriber{}
+	for rows.Next() {
+		var s Subscriber
+		err := rows.Scan(&s.UserID, &s.Name, &s.Email, &s.Locale, pq.Array(&s.FollowedGenres), &s.LastSentAt)
+		if err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, &s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return subscribers, nil
+}
+
+// Deliver records that kind was sent to the subscriber and, unless template
+// is empty, queues the email in the same transaction. An empty template just
+// moves the subscriber to the back of the queue, for when there was nothing
+// worth sending.
+func (m NotificationModel) Deliver(kind string, s *Subscriber, template string, data interface{}) error {
+	columns, ok := notificationColumns[kind]
+	if !ok {
+		return fmt.Errorf("unknown notification kind %q", kind)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := fmt.Sprintf(`UPDATE notification_settings SET %s = NOW() WHERE user_id = $1`, columns[1])
+	_, err = tx.ExecContext(ctx, query, s.UserID)
+	if err != nil {
+		return err
+	}
+	if template != "" {
+		err = insertEmail(ctx, tx, s.Email, s.Locale, template, data)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PuzzleOfTheDay picks a published puzzle for the daily reminder. The choice
+// only depends on day, so every reminder sent that day points to the same
+// puzzle. It returns ErrRecordNotFound when nothing is published.
+func (m NotificationModel) PuzzleOfTheDay(day time.Time) (*PuzzleSummary, error) {
+	query := `
+		SELECT id, title, genres
+		FROM puzzles
+		WHERE status = 'published' AND deleted_at IS NULL
+		ORDER BY md5(id::text || $1), id
+		LIMIT 1`
+	var pack PuzzleSummary
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, day.Format("2006-01-02")).Scan(&pack.ID, &pack.Title, pq.Array(&pack.Genres))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &pack, nil
+}
+
+// NewPacks lists puzzles in any of genres that were published after since,
+// leaving out the user's own.
+func (m NotificationModel) NewPacks(userID int64, genres []string, since time.Time, limit int) ([]*PuzzleSummary, error) {
+	query := `
+		SELECT id, title, genres
+		FROM puzzles
+		WHERE status = 'published' AND deleted_at IS NULL
+		AND genres && $1 AND owner_id <> $2
+		AND EXISTS (
+			SELECT 1 FROM puzzle_revisions
+			WHERE puzzle_revisions.puzzle_id = puzzles.id
+			AND puzzle_revisions.status = 'published' AND puzzle_revisions.changes ? 'status'
+			AND puzzle_revisions.created_at > $3
+		)
+		ORDER BY id DESC
+		LIMIT $4`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(genres), userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	packs := []*PuzzleSummary{}
+	for rows.Next() {
+		var pack PuzzleSummary
+		err := rows.Scan(&pack.ID, &pack.Title, pq.Array(&pack.Genres))
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, &pack)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return packs, nil
+}
+
+// WeeklyStats counts puzzles published across the site since the given time,
+// and the user's own puzzles: all of them, the published ones and those
+// created since then.
+func (m NotificationModel) WeeklyStats(userID int64, since time.Time) (*WeeklyStats, error) {
+	query := `
+		SELECT
+			(SELECT count(DISTINCT puzzle_id) FROM puzzle_revisions
+				WHERE status = 'published' AND changes ? 'status' AND created_at > $2),
+			count(*),
+			count(*) FILTER (WHERE status = 'published'),
+			count(*) FILTER (WHERE created_at > $2)
+		FROM puzzles
+		WHERE owner_id = $1 AND deleted_at IS NULL`
+	var stats WeeklyStats
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, userID, since).Scan(
+		&stats.Published,
+		&stats.OwnTotal,
+		&stats.OwnPublished,
+		&stats.OwnCreated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,281 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	EmailPending = "pending"
+	EmailSending = "sending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+var EmailStatuses = []string{EmailPending, EmailSending, EmailSent, EmailFailed}
+
+// ErrEmailNotRetryable is returned when retrying an email that has not failed.
+var ErrEmailNotRetryable = errors.New("email has not failed")
+
+// Email is a message waiting in the outbox. It is written in the same
+// transaction as the change that calls for it, so an email is never lost to
+// a crash and never sent for a change that was rolled back. The dispatcher
+// renders Template with Data when it delivers the message.
+type Email struct {
+	ID            int64           `json:"id"`
+	Recipient     string          `json:"recipient"`
+	Locale        string          `json:"locale"`
+	Template      string          `json:"template"`
+	Data          json.RawMessage `json:"data"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	MaxAttempts   int             `json:"max_attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	SentAt        *time.Time      `json:"sent_at,omitempty"`
+}
+
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+// Insert queues an email on its own, for messages that do not go with any
+// other change. The template is rendered in the recipient's locale.
+func (m OutboxModel) Insert(recipient, locale, template string, data interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return insertEmail(ctx, m.DB, recipient, locale, template, data)
+}
+
+func insertEmail(ctx context.Context, db execer, recipient, locale, template string, data interface{}) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO email_outbox (recipient, locale, template, data)
+		VALUES ($1, $2, $3, $4)`
+	_, err = db.ExecContext(ctx, query, recipient, locale, template, js)
+	return err
+}
+
+// Claim takes the next email that is due and marks it as sending. Concurrent
+// dispatchers skip rows locked by each other, so an email is only handed out
+// once. It returns ErrRecordNotFound when nothing is due.
+func (m OutboxModel) Claim() (*Email, error) {
+	query := `
+		UPDATE email_outbox
+		SET status = 'sending', attempts = attempts + 1, locked_at = NOW()
+		WHERE id = (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, recipient, locale, template, data, status, attempts, max_attempts, next_attempt_at, last_error, created_at, sent_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	email, err := scanEmail(m.DB.QueryRowContext(ctx, query))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return email, nil
+}
+
+// MarkSent records a delivered email. Its template data is cleared, since it
+// may hold secrets such as activation tokens that must not outlive the send.
+func (m OutboxModel) MarkSent(email *Email) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'sent', locked_at = NULL, last_error = '', sent_at = NOW(), data = '{}'
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, email.ID)
+	email.Status = EmailSent
+	email.Data = json.RawMessage(`{}`)
+	return err
+}
+
+// MarkFailed records a failed delivery. The email is tried again at retryAt,
+// or marked as failed once it has no attempts left.
+func (m OutboxModel) MarkFailed(email *Email, sendErr error, retryAt time.Time) error {
+	query := `
+		UPDATE email_outbox
+		SET status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = $2, locked_at = NULL, last_error = $3
+		WHERE id = $1
+		RETURNING status`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return m.DB.QueryRowContext(ctx, query, email.ID, retryAt, sendErr.Error()).Scan(&email.Status)
+}
+
+// ReleaseStale puts emails that have been sending since before lockedBefore
+// back in the outbox. Such an email may or may not have gone out before the
+// dispatcher died; sending it again is preferred over never sending it.
+func (m OutboxModel) ReleaseStale(lockedBefore time.Time) (int64, error) {
+	query := `
+		UPDATE email_outbox
+		SET status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'pending' END,
+			locked_at = NULL, last_error = 'dispatcher stopped while sending the email'
+		WHERE status = 'sending' AND locked_at < $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, lockedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Retry gives a failed email a fresh set of attempts.
+func (m OutboxModel) Retry(id int64) (*Email, error) {
+	query := `
+		UPDATE email_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND status = 'failed'
+		RETURNING id, recipient, locale, template, data, status, attempts, max_attempts, next_attempt_at, last_error, created_at, sent_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	email, err := scanEmail(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			_, err := m.Get(id)
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrEmailNotRetryable
+		default:
+			return nil, err
+		}
+	}
+	return email, nil
+}
+
+// DeleteSent removes emails that were sent before the given time. Failed
+// emails are kept until an admin has looked at them.
+func (m OutboxModel) DeleteSent(before time.Time) (int64, error) {
+	query := `
+		DELETE FROM email_outbox
+		WHERE status = 'sent' AND sent_at < $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m OutboxModel) Get(id int64) (*Email, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT id, recipient, locale, template, data, status, attempts, max_attempts, next_attempt_at, last_error, created_at, sent_at
+		FROM email_outbox
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	email, err := scanEmail(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return email, nil
+}
+
+// GetAll lists emails, newest first. Empty status or recipient match
+// everything.
+func (m OutboxModel) GetAll(status, recipient string, filters Filters) ([]*Email, Metadata, error) {
+	query := `
+		SELECT count(*) OVER(), id, recipient, locale, template, data, status, attempts, max_attempts, next_attempt_at, last_error, created_at, sent_at
+		FROM email_outbox
+		WHERE (status = $1 OR $1 = '')
+		AND (LOWER(recipient) = LOWER($2) OR $2 = '')
+		ORDER BY id DESC
+		LIMIT $3 OFFSET $4`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, status, recipient, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	emails := []*Email{}
+	for rows.Next() {
+		var email Email
+		var data []byte
+		err := rows.Scan(
+			&totalRecords,
+			&email.ID,
+			&email.Recipient,
+			&email.Locale,
+			&email.Template,
+			&data,
+			&email.Status,
+			&email.Attempts,
+			&email.MaxAttempts,
+			&email.NextAttemptAt,
+			&email.LastError,
+			&email.CreatedAt,
+			&email.SentAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		email.Data = data
+		emails = append(emails, &email)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return emails, metadata, nil
+}
+
+func scanEmail(row *sql.Row) (*Email, error) {
+	var email Email
+	var data []byte
+	err := row.Scan(
+		&email.ID,
+		&email.Recipient,
+		&email.Locale,
+		&email.Template,
+		&data,
+		&email.Status,
+		&email.Attempts,
+		&email.MaxAttempts,
+		&email.NextAttemptAt,
+		&email.LastError,
+		&email.CreatedAt,
+		&email.SentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	email.Data = data
+	return &email, nil
+}
+
+func ValidateEmailFilters(v *validator.Validator, status string) {
+	v.Check(status == "" || validator.In(status, EmailStatuses...), "status", "validation.email_status")
+}
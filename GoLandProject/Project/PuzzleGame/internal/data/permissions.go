@@ -1,6 +1,7 @@
 package data
 
 import (
+	"Puzzle.Ayan.net/internal/validator"
 	"context"
 	"database/sql"
 	"github.com/lib/pq"
@@ -27,8 +28,13 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 SELECT permissions.code
 FROM permissions
 INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
-INNER JOIN users ON users_permissions.user_id = users.id
-WHERE users.id = $1`
+WHERE users_permissions.user_id = $1
+UNION
+SELECT permissions.code
+FROM permissions
+INNER JOIN roles_permissions ON roles_permissions.permission_id = permissions.id
+INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+WHERE users_roles.user_id = $1`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	rows, err := m.DB.QueryContext(ctx, query, userID)
@@ -50,12 +56,62 @@ WHERE users.id = $1`
 	}
 	return permissions, nil
 }
-func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+
+// AddForUser grants the permissions and records entry in the audit log in
+// the same transaction.
+func (m PermissionModel) AddForUser(userID int64, entry *AuditEntry, codes ...string) error {
 	query := `
 INSERT INTO users_permissions
-SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
+SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+ON CONFLICT DO NOTHING`
+	return withAudit(m.DB, entry, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query, userID, pq.Array(codes))
+		return err
+	})
+}
+func (m PermissionModel) RemoveForUser(userID int64, entry *AuditEntry, codes ...string) error {
+	query := `
+DELETE FROM users_permissions
+USING permissions
+WHERE users_permissions.permission_id = permissions.id
+AND users_permissions.user_id = $1
+AND permissions.code = ANY($2)`
+	return withAudit(m.DB, entry, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query, userID, pq.Array(codes))
+		return err
+	})
+}
+func (m PermissionModel) GetAll() (Permissions, error) {
+	query := `
+SELECT code
+FROM permissions
+ORDER BY code`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
-	return err
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func ValidatePermissionCodes(v *validator.Validator, codes []string, known Permissions) {
+	v.Check(len(codes) >= 1, "codes", "validation.min_codes")
+	v.Check(validator.Unique(codes), "codes", "validation.duplicates")
+	for _, code := range codes {
+		v.Check(known.Include(code), "codes", "validation.unknown_codes")
+	}
 }
@@ -7,27 +7,57 @@ import (
 	"errors"
 	"fmt"
 	"github.com/lib/pq"
+	"strconv"
 	"time"
 )
 
+const (
+	StatusDraft     = "draft"
+	StatusInReview  = "in_review"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+var statusTransitions = map[string][]string{
+	StatusDraft:     {StatusInReview},
+	StatusInReview:  {StatusPublished, StatusDraft},
+	StatusPublished: {StatusArchived},
+	StatusArchived:  {},
+}
+
+var Statuses = []string{StatusDraft, StatusInReview, StatusPublished, StatusArchived}
+
+func CanTransition(from, to string) bool {
+	return validator.In(to, statusTransitions[from]...)
+}
+
 type Puzzle struct {
-	ID           int64     `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	Title        string    `json:"title"`
-	NumOfPuzzles NOP       `json:"num_of_puzzles,omitempty,string"`
-	Genres       []string  `json:"genres,omitempty"`
-	Version      int32     `json:"version"`
+	ID           int64      `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Title        string     `json:"title"`
+	NumOfPuzzles NOP        `json:"num_of_puzzles,omitempty,string"`
+	Genres       []string   `json:"genres,omitempty"`
+	OwnerID      int64      `json:"owner_id"`
+	Status       string     `json:"status"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	Thumbnail    string     `json:"-"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+	Rating       float64    `json:"rating"`
+	RatingCount  int        `json:"rating_count"`
+	Version      int32      `json:"version"`
 }
 
 func ValidateMovie(v *validator.Validator, puzzle *Puzzle) {
-	v.Check(puzzle.Title != "", "title", "must be provided")
-	v.Check(len(puzzle.Title) <= 500, "title", "must not be more than 500 bytes long")
-	v.Check(puzzle.NumOfPuzzles != 0, "number of puzzles", "must be provided")
-	v.Check(puzzle.NumOfPuzzles > 0, "number of puzzles", "must be a positive integer")
-	v.Check(puzzle.Genres != nil, "genres", "must be provided")
-	v.Check(len(puzzle.Genres) >= 1, "genres", "must contain at least 1 genre")
-	v.Check(len(puzzle.Genres) <= 5, "genres", "must not contain more than 5 genres")
-	v.Check(validator.Unique(puzzle.Genres), "genres", "must not contain duplicate values")
+	v.Check(puzzle.Title != "", "title", "validation.required")
+	v.Check(len(puzzle.Title) <= 500, "title", "validation.max_bytes", 500)
+	v.Check(puzzle.NumOfPuzzles != 0, "number of puzzles", "validation.required")
+	v.Check(puzzle.NumOfPuzzles > 0, "number of puzzles", "validation.positive")
+	v.Check(puzzle.Genres != nil, "genres", "validation.required")
+	v.Check(len(puzzle.Genres) >= 1, "genres", "validation.min_genres")
+	v.Check(len(puzzle.Genres) <= 5, "genres", "validation.max_genres", 5)
+	v.Check(validator.Unique(puzzle.Genres), "genres", "validation.duplicates")
 }
 
 type PuzzleModel struct {
@@ -35,14 +65,64 @@ type PuzzleModel struct {
 }
 
 func (m PuzzleModel) Insert(puzzle *Puzzle) error {
-	query := `
-		INSERT INTO puzzles (title, NumOfPuzzles, genres)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at, version`
-	args := []interface{}{puzzle.Title, puzzle.NumOfPuzzles, pq.Array(puzzle.Genres)}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&puzzle.ID, &puzzle.CreatedAt, &puzzle.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = insertPuzzle(ctx, tx, puzzle)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertPuzzle(ctx context.Context, tx *sql.Tx, puzzle *Puzzle) error {
+	query := `
+		INSERT INTO puzzles (title, NumOfPuzzles, genres, owner_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, status, version`
+	args := []interface{}{puzzle.Title, puzzle.NumOfPuzzles, pq.Array(puzzle.Genres), puzzle.OwnerID}
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&puzzle.ID, &puzzle.CreatedAt, &puzzle.Status, &puzzle.Version)
+	if err != nil {
+		return err
+	}
+	return insertRevision(ctx, tx, puzzle, puzzle.OwnerID, diffPuzzles(nil, puzzle))
+}
+
+// PuzzleBatch inserts many puzzles inside one transaction, so a bulk import
+// either lands completely or not at all. It must be finished with Commit or
+// Rollback.
+type PuzzleBatch struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	tx     *sql.Tx
+}
+
+func (m PuzzleModel) BeginBatch(timeout time.Duration) (*PuzzleBatch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &PuzzleBatch{ctx: ctx, cancel: cancel, tx: tx}, nil
+}
+
+func (b *PuzzleBatch) Insert(puzzle *Puzzle) error {
+	return insertPuzzle(b.ctx, b.tx, puzzle)
+}
+
+func (b *PuzzleBatch) Commit() error {
+	defer b.cancel()
+	return b.tx.Commit()
+}
+
+func (b *PuzzleBatch) Rollback() error {
+	defer b.cancel()
+	return b.tx.Rollback()
 }
 
 func (m PuzzleModel) Get(id int64) (*Puzzle, error) {
@@ -50,9 +130,9 @@ func (m PuzzleModel) Get(id int64) (*Puzzle, error) {
 		return nil, ErrRecordNotFound
 	}
 	query := `
-		SELECT id, created_at, numOfPuzzles, genres, version
+		SELECT id, created_at, title, numOfPuzzles, genres, COALESCE(owner_id, 0), status, thumbnail, rating_avg, rating_count, version
 		FROM puzzles
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 	var puzzle Puzzle
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -62,6 +142,11 @@ func (m PuzzleModel) Get(id int64) (*Puzzle, error) {
 		&puzzle.Title,
 		&puzzle.NumOfPuzzles,
 		pq.Array(&puzzle.Genres),
+		&puzzle.OwnerID,
+		&puzzle.Status,
+		&puzzle.Thumbnail,
+		&puzzle.Rating,
+		&puzzle.RatingCount,
 		&puzzle.Version,
 	)
 	if err != nil {
@@ -72,10 +157,13 @@ func (m PuzzleModel) Get(id int64) (*Puzzle, error) {
 			return nil, err
 		}
 	}
+	puzzle.setThumbnailURL()
 	return &puzzle, nil
 }
 
-func (m PuzzleModel) Update(puzzle *Puzzle) error {
+// Update saves the puzzle and records the change as a new revision made by
+// the given user.
+func (m PuzzleModel) Update(puzzle *Puzzle, userID int64) error {
 	query := `
 		UPDATE puzzles
 		SET title = $1, numOfPuzzles = $2, genres = $3, version = version + 1
@@ -88,9 +176,59 @@ func (m PuzzleModel) Update(puzzle *Puzzle) error {
 		puzzle.ID,
 		puzzle.Version,
 	}
+	return m.updateWithRevision(puzzle, userID, query, args)
+}
+
+// Transition moves the puzzle to the given status. The move is rejected with
+// ErrInvalidTransition unless it is allowed from the puzzle's current status,
+// and with ErrEditConflict if the row changed since the puzzle was read.
+func (m PuzzleModel) Transition(puzzle *Puzzle, status string, userID int64) error {
+	if !CanTransition(puzzle.Status, status) {
+		return ErrInvalidTransition
+	}
+	query := `
+		UPDATE puzzles
+		SET status = $1, version = version + 1
+		WHERE id = $2 AND version = $3 AND status = $4
+		RETURNING version`
+	args := []interface{}{status, puzzle.ID, puzzle.Version, puzzle.Status}
+	from := puzzle.Status
+	puzzle.Status = status
+	err := m.updateWithRevision(puzzle, userID, query, args)
+	if err != nil {
+		puzzle.Status = from
+	}
+	return err
+}
+
+func (m PuzzleModel) updateWithRevision(puzzle *Puzzle, userID int64, query string, args []interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&puzzle.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var previous Puzzle
+	err = tx.QueryRowContext(ctx, `
+		SELECT title, numOfPuzzles, genres, status
+		FROM puzzles
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		FOR UPDATE`, puzzle.ID, puzzle.Version).Scan(
+		&previous.Title,
+		&previous.NumOfPuzzles,
+		pq.Array(&previous.Genres),
+		&previous.Status,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&puzzle.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -99,45 +237,243 @@ func (m PuzzleModel) Update(puzzle *Puzzle) error {
 			return err
 		}
 	}
-	return nil
+	err = insertRevision(ctx, tx, puzzle, userID, diffPuzzles(&previous, puzzle))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (m PuzzleModel) Delete(id int64) error {
+// Delete moves the puzzle to the trash. Like any other change it bumps the
+// version and is recorded as a revision by userID.
+func (m PuzzleModel) Delete(puzzle *Puzzle, userID int64) error {
+	query := `
+		UPDATE puzzles
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		RETURNING deleted_at, version`
+	return m.setTrashed(puzzle, userID, query, true)
+}
+
+func (m PuzzleModel) GetDeleted(id int64) (*Puzzle, error) {
 	if id < 1 {
-		return ErrRecordNotFound
+		return nil, ErrRecordNotFound
 	}
 	query := `
-		DELETE FROM puzzles
-		WHERE id = $1`
+		SELECT id, created_at, title, numOfPuzzles, genres, COALESCE(owner_id, 0), status, deleted_at, thumbnail, rating_avg, rating_count, version
+		FROM puzzles
+		WHERE id = $1 AND deleted_at IS NOT NULL`
+	var puzzle Puzzle
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	result, err := m.DB.ExecContext(ctx, query, id)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&puzzle.ID,
+		&puzzle.CreatedAt,
+		&puzzle.Title,
+		&puzzle.NumOfPuzzles,
+		pq.Array(&puzzle.Genres),
+		&puzzle.OwnerID,
+		&puzzle.Status,
+		&puzzle.DeletedAt,
+		&puzzle.Thumbnail,
+		&puzzle.Rating,
+		&puzzle.RatingCount,
+		&puzzle.Version,
+	)
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
+	puzzle.setThumbnailURL()
+	return &puzzle, nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+// GetAllDeleted lists the puzzles in the trash, most recently deleted first.
+// An ownerID of 0 lists the trash of every user.
+func (m PuzzleModel) GetAllDeleted(ownerID int64, filters Filters) ([]*Puzzle, Metadata, error) {
+	query := `
+		SELECT count(*) OVER(), id, created_at, title, numOfPuzzles, genres, COALESCE(owner_id, 0), status, deleted_at, thumbnail, rating_avg, rating_count, version
+		FROM puzzles
+		WHERE deleted_at IS NOT NULL
+		AND (owner_id = $1 OR $1 = 0)
+		ORDER BY deleted_at DESC, id ASC
+		LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, ownerID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	puzzles := []*Puzzle{}
+	for rows.Next() {
+		var puzzle Puzzle
+		err := rows.Scan(
+			&totalRecords,
+			&puzzle.ID,
+			&puzzle.CreatedAt,
+			&puzzle.Title,
+			&puzzle.NumOfPuzzles,
+			pq.Array(&puzzle.Genres),
+			&puzzle.OwnerID,
+			&puzzle.Status,
+			&puzzle.DeletedAt,
+			&puzzle.Thumbnail,
+			&puzzle.Rating,
+			&puzzle.RatingCount,
+			&puzzle.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		puzzle.setThumbnailURL()
+		puzzles = append(puzzles, &puzzle)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return puzzles, metadata, nil
+}
+
+// Restore takes the puzzle out of the trash, bumping the version and
+// recording a revision by userID.
+func (m PuzzleModel) Restore(puzzle *Puzzle, userID int64) error {
+	query := `
+		UPDATE puzzles
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NOT NULL
+		RETURNING deleted_at, version`
+	return m.setTrashed(puzzle, userID, query, false)
+}
+
+func (m PuzzleModel) setTrashed(puzzle *Puzzle, userID int64, query string, trashed bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, puzzle.ID, puzzle.Version).Scan(&puzzle.DeletedAt, &puzzle.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	changes := map[string]FieldChange{"trashed": {From: !trashed, To: trashed}}
+	err = insertRevision(ctx, tx, puzzle, userID, changes)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Purge permanently removes puzzles that were moved to the trash before the
+// given time and returns how many were removed. Their items go with them,
+// but images and thumbnails live outside the database, so the blob keys of
+// the removed items are returned for the caller to delete.
+func (m PuzzleModel) Purge(deletedBefore time.Time) (int64, []string, error) {
+	// Every part of the statement sees puzzle_items as it was before the
+	// cascade removed the rows.
+	query := `
+		WITH purged AS (
+			DELETE FROM puzzles
+			WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			RETURNING id
+		)
+		SELECT purged.id, COALESCE(blobs.key, '')
+		FROM purged
+		LEFT JOIN (
+			SELECT puzzle_id, content->>'image' AS key FROM puzzle_items WHERE kind = 'jigsaw'
+			UNION ALL
+			SELECT puzzle_id, NULLIF(thumbnail, '') FROM puzzle_items
+		) AS blobs ON blobs.puzzle_id = purged.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	purged := make(map[int64]bool)
+	var blobs []string
+	for rows.Next() {
+		var id int64
+		var blob string
+		err := rows.Scan(&id, &blob)
+		if err != nil {
+			return 0, nil, err
+		}
+		purged[id] = true
+		if blob != "" {
+			blobs = append(blobs, blob)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, nil, err
 	}
-	return nil
+	return int64(len(purged)), blobs, nil
+}
+
+// GetAll returns the puzzles matching the filters. An empty status matches
+// every status, and a minRating of 0 includes puzzles nobody has rated.
+func (m PuzzleModel) GetAll(title string, genres []string, status string, minRating float64, filters Filters) ([]*Puzzle, Metadata, error) {
+	return m.getAll(0, title, genres, status, minRating, filters)
+}
+
+func (m PuzzleModel) GetAllForOwner(ownerID int64, title string, genres []string, status string, minRating float64, filters Filters) ([]*Puzzle, Metadata, error) {
+	return m.getAll(ownerID, title, genres, status, minRating, filters)
+}
+
+// puzzleSortColumns maps the sort keys accepted by the API onto columns of
+// the puzzles table.
+var puzzleSortColumns = map[string]string{
+	"id":             "id",
+	"title":          "title",
+	"num_of_puzzles": "NumOfPuzzles",
+	"rating":         "rating_avg",
 }
 
-func (m PuzzleModel) GetAll(title string, genres []string, filters Filters) ([]*Puzzle, Metadata, error) {
+// puzzleListConditions filters puzzle listings. Genres in the filter may be
+// given by slug or alias and are looked up in the genres table first.
+const puzzleListConditions = `
+		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND (genres @> ARRAY(
+			SELECT COALESCE((
+				SELECT genres.slug FROM genres
+				WHERE genres.slug = lower(filter.genre) OR lower(filter.genre) = ANY(genres.aliases)
+				LIMIT 1
+			), filter.genre)
+			FROM unnest($2::text[]) AS filter (genre)
+		) OR $2 = '{}')
+		AND (owner_id = $3 OR $3 = 0)
+		AND (status = $4 OR $4 = '')
+		AND rating_avg >= $5
+		AND deleted_at IS NULL`
+
+func (m PuzzleModel) getAll(ownerID int64, title string, genres []string, status string, minRating float64, filters Filters) ([]*Puzzle, Metadata, error) {
+	if filters.UseCursor {
+		return m.getAllByCursor(ownerID, title, genres, status, minRating, filters)
+	}
 	query := fmt.Sprintf(`
-		SELECT id, created_at, title, numOfPuzzles, genres, version
+		SELECT count(*) OVER(), id, created_at, title, numOfPuzzles, genres, COALESCE(owner_id, 0), status, thumbnail, rating_avg, rating_count, version
 		FROM puzzles
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (genres @> $2 OR $2 = '{}')
+		%s
 		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+		LIMIT $6 OFFSET $7`, puzzleListConditions, puzzleSortColumns[filters.sortColumn()], filters.sortDirection())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := []interface{}{title, pq.Array(genres), ownerID, status, minRating, filters.limit(), filters.offset()}
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
@@ -154,11 +490,17 @@ func (m PuzzleModel) GetAll(title string, genres []string, filters Filters) ([]*
 			&puzzle.Title,
 			&puzzle.NumOfPuzzles,
 			pq.Array(&puzzle.Genres),
+			&puzzle.OwnerID,
+			&puzzle.Status,
+			&puzzle.Thumbnail,
+			&puzzle.Rating,
+			&puzzle.RatingCount,
 			&puzzle.Version,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
+		puzzle.setThumbnailURL()
 		puzzles = append(puzzles, &puzzle)
 	}
 	if err = rows.Err(); err != nil {
@@ -167,3 +509,157 @@ func (m PuzzleModel) GetAll(title string, genres []string, filters Filters) ([]*
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
 	return puzzles, metadata, nil
 }
+
+// getAllByCursor is the keyset counterpart of getAll. Instead of skipping
+// rows with OFFSET it seeks past the (sort key, id) pair stored in the
+// cursor, so deep pages cost the same as the first one. Going backwards
+// flips the comparison and the ordering and then reverses the rows again.
+func (m PuzzleModel) getAllByCursor(ownerID int64, title string, genres []string, status string, minRating float64, filters Filters) ([]*Puzzle, Metadata, error) {
+	var c cursor
+	if filters.Cursor != "" {
+		var err error
+		c, err = decodeCursor(filters.Cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+	column := puzzleSortColumns[filters.sortColumn()]
+	ascending := filters.sortDirection() == "ASC"
+	if c.Backward {
+		ascending = !ascending
+	}
+	direction, comparison := "ASC", ">"
+	if !ascending {
+		direction, comparison = "DESC", "<"
+	}
+	args := []interface{}{title, pq.Array(genres), ownerID, status, minRating, filters.limit() + 1}
+	seek := ""
+	if filters.Cursor != "" {
+		seek = fmt.Sprintf("AND (%s, id) %s ($7, $8)", column, comparison)
+		args = append(args, c.Value, c.ID)
+	}
+	query := fmt.Sprintf(`
+		SELECT id, created_at, title, numOfPuzzles, genres, COALESCE(owner_id, 0), status, thumbnail, rating_avg, rating_count, version
+		FROM puzzles
+		%s
+		%s
+		ORDER BY %s %s, id %s
+		LIMIT $6`, puzzleListConditions, seek, column, direction, direction)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	puzzles := []*Puzzle{}
+	for rows.Next() {
+		var puzzle Puzzle
+		err := rows.Scan(
+			&puzzle.ID,
+			&puzzle.CreatedAt,
+			&puzzle.Title,
+			&puzzle.NumOfPuzzles,
+			pq.Array(&puzzle.Genres),
+			&puzzle.OwnerID,
+			&puzzle.Status,
+			&puzzle.Thumbnail,
+			&puzzle.Rating,
+			&puzzle.RatingCount,
+			&puzzle.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		puzzle.setThumbnailURL()
+		puzzles = append(puzzles, &puzzle)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	more := len(puzzles) > filters.limit()
+	if more {
+		puzzles = puzzles[:filters.limit()]
+	}
+	if c.Backward {
+		for i, j := 0, len(puzzles)-1; i < j; i, j = i+1, j-1 {
+			puzzles[i], puzzles[j] = puzzles[j], puzzles[i]
+		}
+	}
+	metadata := Metadata{PageSize: filters.PageSize}
+	if len(puzzles) == 0 {
+		return puzzles, metadata, nil
+	}
+	first, last := puzzles[0], puzzles[len(puzzles)-1]
+	if more || c.Backward {
+		metadata.NextCursor = encodeCursor(cursor{Sort: filters.Sort, Value: last.sortValue(column), ID: last.ID})
+	}
+	if (more && c.Backward) || (filters.Cursor != "" && !c.Backward) {
+		metadata.PrevCursor = encodeCursor(cursor{Sort: filters.Sort, Value: first.sortValue(column), ID: first.ID, Backward: true})
+	}
+	return puzzles, metadata, nil
+}
+
+// Stream calls fn for every puzzle matching the filters in sort order. Only
+// the sort of filters is used: there is no paging, and rows are read from the
+// database as fn consumes them rather than collected first. Streaming stops
+// at the first error returned by fn or when ctx is done.
+func (m PuzzleModel) Stream(ctx context.Context, title string, genres []string, status string, minRating float64, filters Filters, fn func(*Puzzle) error) error {
+	query := fmt.Sprintf(`
+		SELECT id, created_at, title, numOfPuzzles, genres, COALESCE(owner_id, 0), status, thumbnail, rating_avg, rating_count, version
+		FROM puzzles
+		%s
+		ORDER BY %s %s, id ASC`, puzzleListConditions, puzzleSortColumns[filters.sortColumn()], filters.sortDirection())
+	rows, err := m.DB.QueryContext(ctx, query, title, pq.Array(genres), 0, status, minRating)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var puzzle Puzzle
+		err := rows.Scan(
+			&puzzle.ID,
+			&puzzle.CreatedAt,
+			&puzzle.Title,
+			&puzzle.NumOfPuzzles,
+			pq.Array(&puzzle.Genres),
+			&puzzle.OwnerID,
+			&puzzle.Status,
+			&puzzle.Thumbnail,
+			&puzzle.Rating,
+			&puzzle.RatingCount,
+			&puzzle.Version,
+		)
+		if err != nil {
+			return err
+		}
+		puzzle.setThumbnailURL()
+		err = fn(&puzzle)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// setThumbnailURL points ThumbnailURL at the endpoint serving the preview,
+// once one has been generated.
+func (p *Puzzle) setThumbnailURL() {
+	if p.Thumbnail != "" {
+		p.ThumbnailURL = fmt.Sprintf("/v1/puzzles/%d/thumbnail", p.ID)
+	}
+}
+
+func (p *Puzzle) sortValue(column string) string {
+	switch column {
+	case "title":
+		return p.Title
+	case "NumOfPuzzles":
+		return strconv.FormatInt(int64(p.NumOfPuzzles), 10)
+	case "rating_avg":
+		return strconv.FormatFloat(p.Rating, 'f', 2, 64)
+	default:
+		return strconv.FormatInt(p.ID, 10)
+	}
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"testing"
+)
+
+func TestSortValue(t *testing.T) {
+	p := &Puzzle{ID: 12, Title: "Grid", NumOfPuzzles: 30, Rating: 4.5}
+	tests := map[string]string{
+		"id":           "12",
+		"title":        "Grid",
+		"NumOfPuzzles": "30",
+		"rating_avg":   "4.50",
+	}
+	for column, want := range tests {
+		if got := p.sortValue(column); got != want {
+			t.Fatalf("sortValue(%q) = %q, want %q", column, got, want)
+		}
+	}
+	// rating_avg is numeric(3, 2), so the cursor must not carry more
+	// precision than the column or the next page would skip ties.
+	p.Rating = 10.0 / 3
+	if got := p.sortValue("rating_avg"); got != "3.33" {
+		t.Fatalf("sortValue(rating_avg) = %q, want 3.33", got)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := cursor{Sort: "-rating", Value: "4.50", ID: 12, Backward: true}
+	got, err := decodeCursor(encodeCursor(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if _, err := decodeCursor("not a cursor"); err == nil {
+		t.Fatalf("expected an error for a malformed cursor")
+	}
+}
+
+func TestValidateRatingCursor(t *testing.T) {
+	safelist := []string{"id", "rating", "-id", "-rating"}
+	tests := []struct {
+		sort   string
+		cursor string
+		valid  bool
+	}{
+		{"-rating", "", true},
+		{"-rating", encodeCursor(cursor{Sort: "-rating", Value: "4.50", ID: 3}), true},
+		{"rating", encodeCursor(cursor{Sort: "-rating", Value: "4.50", ID: 3}), false},
+		{"-rating", "%%%", false},
+	}
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateFilters(v, Filters{Page: 1, PageSize: 10, Sort: tt.sort, SortSafelist: safelist, Cursor: tt.cursor, UseCursor: true})
+		if v.Valid() != tt.valid {
+			t.Fatalf("sort %q, cursor %q: valid is %v, want %v (%v)", tt.sort, tt.cursor, v.Valid(), tt.valid, v.Errors)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDuplicateReview is returned when a user reviews a puzzle twice.
+var ErrDuplicateReview = errors.New("duplicate review")
+
+// Review is a user's rating of a puzzle, with optional text. Each user has at
+// most one review per puzzle and edits it in place.
+type Review struct {
+	ID        int64     `json:"id"`
+	PuzzleID  int64     `json:"puzzle_id"`
+	UserID    int64     `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	Rating    int       `json:"rating"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating >= 1 && review.Rating <= 5, "rating", "validation.between", 1, 5)
+	v.Check(len(review.Body) <= 5000, "body", "validation.max_bytes", 5000)
+}
+
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+// Insert adds the review and updates the rating of its puzzle in the same
+// transaction.
+func (m ReviewModel) Insert(review *Review) error {
+	query := `
+		INSERT INTO reviews (puzzle_id, user_id, rating, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version`
+	args := []interface{}{review.PuzzleID, review.UserID, review.Rating, review.Body}
+	return m.withRating(review.PuzzleID, func(ctx context.Context, tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+		if err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "reviews_puzzle_id_user_id_key"`:
+				return ErrDuplicateReview
+			default:
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns a review of the given puzzle.
+func (m ReviewModel) Get(puzzleID, id int64) (*Review, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT reviews.id, reviews.puzzle_id, reviews.user_id, users.name, reviews.rating, reviews.body,
+			reviews.created_at, reviews.updated_at, reviews.version
+		FROM reviews
+		INNER JOIN users ON users.id = reviews.user_id
+		WHERE reviews.id = $1 AND reviews.puzzle_id = $2`
+	var review Review
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, id, puzzleID).Scan(
+		&review.ID,
+		&review.PuzzleID,
+		&review.UserID,
+		&review.UserName,
+		&review.Rating,
+		&review.Body,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &review, nil
+}
+
+// Update saves the review if it is still at the version it was read at and
+// returns ErrEditConflict otherwise.
+func (m ReviewModel) Update(review *Review) error {
+	query := `
+		UPDATE reviews
+		SET rating = $1, body = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING updated_at, version`
+	args := []interface{}{review.Rating, review.Body, review.ID, review.Version}
+	return m.withRating(review.PuzzleID, func(ctx context.Context, tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&review.UpdatedAt, &review.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m ReviewModel) Delete(review *Review) error {
+	return m.withRating(review.PuzzleID, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM reviews WHERE id = $1`, review.ID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// withRating runs fn in a transaction and then recomputes the rating of the
+// puzzle from its reviews. The puzzle row is locked first, so concurrent
+// reviews of the same puzzle take turns and the last one to commit always
+// counts everybody else's.
+func (m ReviewModel) withRating(puzzleID int64, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var id int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM puzzles WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, puzzleID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	err = fn(ctx, tx)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE puzzles
+		SET rating_avg = COALESCE((SELECT round(avg(rating), 2) FROM reviews WHERE puzzle_id = $1), 0),
+			rating_count = (SELECT count(*) FROM reviews WHERE puzzle_id = $1)
+		WHERE id = $1`
+	_, err = tx.ExecContext(ctx, query, puzzleID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// reviewSortColumns maps the sort keys accepted by the API onto columns of
+// the reviews table.
+var reviewSortColumns = map[string]string{
+	"id":         "reviews.id",
+	"rating":     "reviews.rating",
+	"updated_at": "reviews.updated_at",
+}
+
+// GetAll lists the reviews of a puzzle.
+func (m ReviewModel) GetAll(puzzleID int64, filters Filters) ([]*Review, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), reviews.id, reviews.puzzle_id, reviews.user_id, users.name, reviews.rating, reviews.body,
+			reviews.created_at, reviews.updated_at, reviews.version
+		FROM reviews
+		INNER JOIN users ON users.id = reviews.user_id
+		WHERE reviews.puzzle_id = $1
+		ORDER BY %s %s, reviews.id ASC
+		LIMIT $2 OFFSET $3`, reviewSortColumns[filters.sortColumn()], filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, puzzleID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	reviews := []*Review{}
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.PuzzleID,
+			&review.UserID,
+			&review.UserName,
+			&review.Rating,
+			&review.Body,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		reviews = append(reviews, &review)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return reviews, metadata, nil
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRatingAggregate(t *testing.T) {
+	f := newDBFixture(t, 3)
+	puzzle := f.puzzle("aggregate", 5, 4, 4)
+	if puzzle.RatingCount != 3 || puzzle.Rating != 4.33 {
+		t.Fatalf("got %d ratings averaging %.2f, want 3 averaging 4.33", puzzle.RatingCount, puzzle.Rating)
+	}
+	version := puzzle.Version
+
+	review := &Review{PuzzleID: puzzle.ID, UserID: f.users[1], Rating: 1}
+	if err := f.models.Reviews.Insert(review); !errors.Is(err, ErrDuplicateReview) {
+		t.Fatalf("expected ErrDuplicateReview, got %v", err)
+	}
+	reviews, _, err := f.models.Reviews.GetAll(puzzle.ID, Filters{Page: 1, PageSize: 10, Sort: "id", SortSafelist: []string{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.models.Reviews.Delete(reviews[0]); err != nil {
+		t.Fatal(err)
+	}
+	puzzle = f.get(puzzle.ID)
+	if puzzle.RatingCount != 2 || puzzle.Rating != 4 {
+		t.Fatalf("after a delete got %d ratings averaging %.2f, want 2 averaging 4", puzzle.RatingCount, puzzle.Rating)
+	}
+	// The rating is kept out of the version, which If-Match relies on.
+	if puzzle.Version != version {
+		t.Fatalf("reviews bumped the puzzle version from %d to %d", version, puzzle.Version)
+	}
+
+	for _, review := range reviews[1:] {
+		if err := f.models.Reviews.Delete(review); err != nil {
+			t.Fatal(err)
+		}
+	}
+	puzzle = f.get(puzzle.ID)
+	if puzzle.RatingCount != 0 || puzzle.Rating != 0 {
+		t.Fatalf("unrated puzzle has %d ratings averaging %.2f", puzzle.RatingCount, puzzle.Rating)
+	}
+}
+
+func TestMinRating(t *testing.T) {
+	f := newDBFixture(t, 2)
+	f.puzzle("unrated")
+	f.puzzle("low", 2)
+	f.puzzle("high", 5, 4)
+	filters := Filters{Page: 1, PageSize: 10, Sort: "id", SortSafelist: []string{"id"}}
+	tests := map[float64]int{0: 3, 2: 2, 4.5: 1, 5: 0}
+	for minRating, want := range tests {
+		puzzles, _, err := f.models.Puzzles.GetAll(f.word, nil, "", minRating, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(puzzles) != want {
+			t.Fatalf("min_rating %g matched %d puzzles, want %d", minRating, len(puzzles), want)
+		}
+	}
+}
+
+func TestRatingSortAndCursor(t *testing.T) {
+	f := newDBFixture(t, 2)
+	// Two puzzles tie on the rating, so the cursor has to fall back on the id.
+	want := []*Puzzle{
+		f.puzzle("top", 5),
+		f.puzzle("tie a", 4, 3),
+		f.puzzle("tie b", 3, 4),
+		f.puzzle("low", 1),
+		f.puzzle("unrated"),
+	}
+	safelist := []string{"rating", "-rating"}
+
+	puzzles, _, err := f.models.Puzzles.GetAll(f.word, nil, "", 0, Filters{Page: 1, PageSize: 10, Sort: "-rating", SortSafelist: safelist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, "offset paging", puzzles, want)
+
+	var got []*Puzzle
+	filters := Filters{Page: 1, PageSize: 2, Sort: "-rating", SortSafelist: safelist, UseCursor: true}
+	var last Metadata
+	for page := 0; page < 5; page++ {
+		puzzles, metadata, err := f.models.Puzzles.GetAll(f.word, nil, "", 0, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, puzzles...)
+		last = metadata
+		if metadata.NextCursor == "" {
+			break
+		}
+		filters.Cursor = metadata.NextCursor
+	}
+	checkOrder(t, "cursor paging", got, want)
+
+	filters.Cursor = last.PrevCursor
+	puzzles, _, err = f.models.Puzzles.GetAll(f.word, nil, "", 0, filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOrder(t, "paging backwards", puzzles, want[2:4])
+}
+
+func checkOrder(t *testing.T, name string, got, want []*Puzzle) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d puzzles, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID {
+			t.Fatalf("%s: puzzle %d is %q, want %q", name, i, got[i].Title, want[i].Title)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+type PuzzleRevision struct {
+	PuzzleID     int64                  `json:"puzzle_id"`
+	Version      int32                  `json:"version"`
+	UserID       int64                  `json:"user_id"`
+	CreatedAt    time.Time              `json:"created_at"`
+	Title        string                 `json:"title"`
+	NumOfPuzzles NOP                    `json:"num_of_puzzles"`
+	Genres       []string               `json:"genres"`
+	Status       string                 `json:"status"`
+	Changes      map[string]FieldChange `json:"changes"`
+}
+
+// diffPuzzles lists the fields that differ between two versions of a puzzle.
+// A nil previous puzzle describes the initial revision, where every field is
+// reported as new.
+func diffPuzzles(previous, current *Puzzle) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	if previous == nil {
+		changes["title"] = FieldChange{To: current.Title}
+		changes["num_of_puzzles"] = FieldChange{To: current.NumOfPuzzles}
+		changes["genres"] = FieldChange{To: current.Genres}
+		changes["status"] = FieldChange{To: current.Status}
+		return changes
+	}
+	if previous.Title != current.Title {
+		changes["title"] = FieldChange{From: previous.Title, To: current.Title}
+	}
+	if previous.NumOfPuzzles != current.NumOfPuzzles {
+		changes["num_of_puzzles"] = FieldChange{From: previous.NumOfPuzzles, To: current.NumOfPuzzles}
+	}
+	if !equalStrings(previous.Genres, current.Genres) {
+		changes["genres"] = FieldChange{From: previous.Genres, To: current.Genres}
+	}
+	if previous.Status != current.Status {
+		changes["status"] = FieldChange{From: previous.Status, To: current.Status}
+	}
+	return changes
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func insertRevision(ctx context.Context, tx *sql.Tx, puzzle *Puzzle, userID int64, changes map[string]FieldChange) error {
+	js, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO puzzle_revisions (puzzle_id, version, user_id, title, num_of_puzzles, genres, status, changes)
+		VALUES ($1, $2, NULLIF($3::bigint, 0), $4, $5, $6, $7, $8)`
+	args := []interface{}{
+		puzzle.ID,
+		puzzle.Version,
+		userID,
+		puzzle.Title,
+		puzzle.NumOfPuzzles,
+		pq.Array(puzzle.Genres),
+		puzzle.Status,
+		js,
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+type RevisionModel struct {
+	DB *sql.DB
+}
+
+func (m RevisionModel) Get(puzzleID int64, version int32) (*PuzzleRevision, error) {
+	query := `
+		SELECT puzzle_id, version, COALESCE(user_id, 0), created_at, title, num_of_puzzles, genres, status, changes
+		FROM puzzle_revisions
+		WHERE puzzle_id = $1 AND version = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	revision, err := scanRevision(m.DB.QueryRowContext(ctx, query, puzzleID, version))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return revision, nil
+}
+
+func (m RevisionModel) GetAll(puzzleID int64, filters Filters) ([]*PuzzleRevision, Metadata, error) {
+	query := `
+		SELECT count(*) OVER(), puzzle_id, version, COALESCE(user_id, 0), created_at, title, num_of_puzzles, genres, status, changes
+		FROM puzzle_revisions
+		WHERE puzzle_id = $1
+		ORDER BY version DESC
+		LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, puzzleID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	revisions := []*PuzzleRevision{}
+	for rows.Next() {
+		var revision PuzzleRevision
+		var changes []byte
+		err := rows.Scan(
+			&totalRecords,
+			&revision.PuzzleID,
+			&revision.Version,
+			&revision.UserID,
+			&revision.CreatedAt,
+			&revision.Title,
+			&revision.NumOfPuzzles,
+			pq.Array(&revision.Genres),
+			&revision.Status,
+			&changes,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		err = json.Unmarshal(changes, &revision.Changes)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return revisions, metadata, nil
+}
+
+func scanRevision(row *sql.Row) (*PuzzleRevision, error) {
+	var revision PuzzleRevision
+	var changes []byte
+	err := row.Scan(
+		&revision.PuzzleID,
+		&revision.Version,
+		&revision.UserID,
+		&revision.CreatedAt,
+		&revision.Title,
+		&revision.NumOfPuzzles,
+		pq.Array(&revision.Genres),
+		&revision.Status,
+		&changes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(changes, &revision.Changes)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
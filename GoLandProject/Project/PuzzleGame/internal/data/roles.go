@@ -0,0 +1,123 @@
+package data
+
+import (
+	"Puzzle.Ayan.net/internal/validator"
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"time"
+)
+
+type Role struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Permissions Permissions `json:"permissions"`
+}
+
+type RoleModel struct {
+	DB *sql.DB
+}
+
+func (m RoleModel) GetAll() ([]*Role, error) {
+	query := `
+SELECT roles.id, roles.name, COALESCE(array_agg(permissions.code ORDER BY permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')
+FROM roles
+LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+LEFT JOIN permissions ON roles_permissions.permission_id = permissions.id
+GROUP BY roles.id
+ORDER BY roles.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []*Role{}
+	for rows.Next() {
+		var role Role
+		err := rows.Scan(&role.ID, &role.Name, pq.Array(&role.Permissions))
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+func (m RoleModel) GetAllForUser(userID int64) ([]string, error) {
+	query := `
+SELECT roles.name
+FROM roles
+INNER JOIN users_roles ON users_roles.role_id = roles.id
+WHERE users_roles.user_id = $1
+ORDER BY roles.id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		err := rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// AddForUser grants the roles and records entry in the audit log in the
+// same transaction.
+func (m RoleModel) AddForUser(userID int64, entry *AuditEntry, names ...string) error {
+	return withAudit(m.DB, entry, func(ctx context.Context, tx *sql.Tx) error {
+		return addRolesForUser(ctx, tx, userID, names...)
+	})
+}
+
+func addRolesForUser(ctx context.Context, db execer, userID int64, names ...string) error {
+	query := `
+INSERT INTO users_roles
+SELECT $1, roles.id FROM roles WHERE roles.name = ANY($2)
+ON CONFLICT DO NOTHING`
+	_, err := db.ExecContext(ctx, query, userID, pq.Array(names))
+	return err
+}
+func (m RoleModel) RemoveForUser(userID int64, entry *AuditEntry, names ...string) error {
+	query := `
+DELETE FROM users_roles
+USING roles
+WHERE users_roles.role_id = roles.id
+AND users_roles.user_id = $1
+AND roles.name = ANY($2)`
+	return withAudit(m.DB, entry, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query, userID, pq.Array(names))
+		return err
+	})
+}
+
+func ValidateRoleNames(v *validator.Validator, names []string, known []*Role) {
+	v.Check(len(names) >= 1, "roles", "validation.min_roles")
+	v.Check(validator.Unique(names), "roles", "validation.duplicates")
+	for _, name := range names {
+		v.Check(roleExists(known, name), "roles", "validation.unknown_roles")
+	}
+}
+
+func roleExists(roles []*Role, name string) bool {
+	for _, role := range roles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
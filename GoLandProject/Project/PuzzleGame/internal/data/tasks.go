@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// taskLockNamespace is the first key of the advisory locks taken for
+// scheduled tasks, keeping them apart from any other advisory locks.
+const taskLockNamespace = 4242
+
+type TaskModel struct {
+	DB *sql.DB
+}
+
+// RunExclusive runs fn unless another server is running the same task or due
+// says the last run, judged by the database clock, was too recent. A
+// session-level advisory lock makes the check and the run exclusive across
+// replicas, and the start time of every run is kept in scheduled_tasks. A task
+// that never ran is always due. It reports whether fn was called.
+// Failing to release the lock is reported as well, and the connection is then
+// thrown away rather than returned to the pool, which ends the session and
+// frees the lock with it.
+func (m TaskModel) RunExclusive(name string, due func(lastRun, now time.Time) bool, fn func() error) (ran bool, err error) {
+	ctx := context.Background()
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	lockCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	var locked bool
+	err = conn.QueryRowContext(lockCtx, `SELECT pg_try_advisory_lock($1, hashtext($2))`, taskLockNamespace, name).Scan(&locked)
+	if err != nil || !locked {
+		return false, err
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+		var unlocked bool
+		unlockErr := conn.QueryRowContext(unlockCtx, `SELECT pg_advisory_unlock($1, hashtext($2))`, taskLockNamespace, name).Scan(&unlocked)
+		if unlockErr == nil && !unlocked {
+			unlockErr = fmt.Errorf("advisory lock for task %q was not held", name)
+		}
+		if unlockErr != nil {
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			err = errors.Join(err, fmt.Errorf("release lock: %w", unlockErr))
+		}
+	}()
+
+	var now time.Time
+	var lastRun sql.NullTime
+	err = conn.QueryRowContext(lockCtx, `SELECT NOW(), (SELECT last_run_at FROM scheduled_tasks WHERE name = $1)`, name).Scan(&now, &lastRun)
+	if err != nil {
+		return false, err
+	}
+	if lastRun.Valid && !due(lastRun.Time, now) {
+		return false, nil
+	}
+	query := `
+		INSERT INTO scheduled_tasks (name, last_run_at)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET last_run_at = EXCLUDED.last_run_at`
+	_, err = conn.ExecContext(lockCtx, query, name, now)
+	if err != nil {
+		return false, err
+	}
+	return true, fn()
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRunExclusive(t *testing.T) {
+	db := openTestDB(t)
+	m := TaskModel{DB: db}
+	name := fmt.Sprintf("datatest%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM scheduled_tasks WHERE name = $1`, name)
+	})
+	always := func(lastRun, now time.Time) bool { return true }
+
+	// The first call holds the lock until the second one has given up.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	var ran [2]bool
+	var errs [2]error
+	go func() {
+		defer close(finished)
+		ran[0], errs[0] = m.RunExclusive(name, always, func() error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	select {
+	case <-started:
+	case <-finished:
+		t.Fatalf("the first call did not run: %v", errs[0])
+	}
+	ran[1], errs[1] = m.RunExclusive(name, always, func() error {
+		t.Errorf("the second call ran while the first held the lock")
+		return nil
+	})
+	close(release)
+	<-finished
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	if !ran[0] || ran[1] {
+		t.Fatalf("got ran %v, want only the first call to run", ran)
+	}
+
+	// Once the lock is free the last run decides.
+	ran[0], errs[0] = m.RunExclusive(name, func(lastRun, now time.Time) bool { return false }, func() error {
+		t.Errorf("a task that is not due ran")
+		return nil
+	})
+	if errs[0] != nil || ran[0] {
+		t.Fatalf("task that is not due: got %v, %v", ran[0], errs[0])
+	}
+	ran[0], errs[0] = m.RunExclusive(name, always, func() error { return nil })
+	if errs[0] != nil || !ran[0] {
+		t.Fatalf("task that is due: got %v, %v", ran[0], errs[0])
+	}
+}
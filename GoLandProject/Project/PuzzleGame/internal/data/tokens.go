@@ -40,8 +40,8 @@ func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 	return token, nil
 }
 func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
-	v.Check(tokenPlaintext != "", "token", "must be provided")
-	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
+	v.Check(tokenPlaintext != "", "token", "validation.required")
+	v.Check(len(tokenPlaintext) == 26, "token", "validation.exact_bytes", 26)
 }
 
 type TokenModel struct {
@@ -57,13 +57,17 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 	return token, err
 }
 func (m TokenModel) Insert(token *Token) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return insertToken(ctx, m.DB, token)
+}
+
+func insertToken(ctx context.Context, db execer, token *Token) error {
 	query := `
 INSERT INTO tokens (hash, user_id, expiry, scope)
 VALUES ($1, $2, $3, $4)`
 	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	_, err := m.DB.ExecContext(ctx, query, args...)
+	_, err := db.ExecContext(ctx, query, args...)
 	return err
 }
 func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
@@ -75,3 +79,17 @@ WHERE scope = $1 AND user_id = $2`
 	_, err := m.DB.ExecContext(ctx, query, scope, userID)
 	return err
 }
+
+// DeleteExpired removes tokens of every scope that are past their expiry.
+func (m TokenModel) DeleteExpired() (int64, error) {
+	query := `
+DELETE FROM tokens
+WHERE expiry < NOW()`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
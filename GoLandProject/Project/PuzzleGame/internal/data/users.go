@@ -1,12 +1,15 @@
 package data
 
 import (
+	"Puzzle.Ayan.net/internal/i18n"
 	"Puzzle.Ayan.net/internal/validator"
 	"context"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
 	"time"
 )
 
@@ -22,6 +25,7 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  password  `json:"-"`
 	Activated bool      `json:"activated"`
+	Locale    string    `json:"locale"`
 	Version   int       `json:"-"`
 }
 
@@ -56,18 +60,19 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 func ValidateEmail(v *validator.Validator, email string) {
-	v.Check(email != "", "email", "must be provided")
-	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
+	v.Check(email != "", "email", "validation.required")
+	v.Check(validator.Matches(email, validator.EmailRX), "email", "validation.email")
 }
 func ValidatePasswordPlaintext(v *validator.Validator, password string) {
-	v.Check(password != "", "password", "must be provided")
-	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
-	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+	v.Check(password != "", "password", "validation.required")
+	v.Check(len(password) >= 8, "password", "validation.min_bytes", 8)
+	v.Check(len(password) <= 72, "password", "validation.max_bytes", 72)
 }
 func ValidateUser(v *validator.Validator, user *User) {
-	v.Check(user.Name != "", "name", "must be provided")
-	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
+	v.Check(user.Name != "", "name", "validation.required")
+	v.Check(len(user.Name) <= 500, "name", "validation.max_bytes", 500)
 	ValidateEmail(v, user.Email)
+	v.Check(user.Locale == "" || i18n.Supported(user.Locale), "locale", "validation.one_of", strings.Join(i18n.Locales, ", "))
 	if user.Password.plaintext != nil {
 		ValidatePasswordPlaintext(v, *user.Password.plaintext)
 	}
@@ -80,15 +85,50 @@ type UserModel struct {
 	DB *sql.DB
 }
 
-func (m UserModel) Insert(user *User) error {
-	query := `
-INSERT INTO users (name, email, password_hash, activated)
-VALUES ($1, $2, $3, $4)
-RETURNING id, created_at, version`
-	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Activated}
+// Register saves a newly signed up user together with their role and an
+// activation token, and queues the welcome email carrying that token, all in
+// one transaction. emailData builds the template data from the token.
+func (m UserModel) Register(user *User, role string, activationTTL time.Duration, template string, emailData func(token *Token) interface{}) (*Token, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	err = insertUser(ctx, tx, user)
+	if err != nil {
+		return nil, err
+	}
+	err = addRolesForUser(ctx, tx, user.ID, role)
+	if err != nil {
+		return nil, err
+	}
+	token, err := generateToken(user.ID, activationTTL, ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+	err = insertToken(ctx, tx, token)
+	if err != nil {
+		return nil, err
+	}
+	err = insertEmail(ctx, tx, user.Email, user.Locale, template, emailData(token))
+	if err != nil {
+		return nil, err
+	}
+	return token, tx.Commit()
+}
+
+func insertUser(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `
+INSERT INTO users (name, email, password_hash, activated, locale)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at, version`
+	if user.Locale == "" {
+		user.Locale = i18n.DefaultLocale
+	}
+	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Activated, user.Locale}
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -99,9 +139,83 @@ RETURNING id, created_at, version`
 	}
 	return nil
 }
+
+func (m UserModel) Get(id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+SELECT id, created_at, name, email, password_hash, activated, locale, version
+FROM users
+WHERE id = $1`
+	var user User
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Locale,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+func (m UserModel) GetAll(name string, email string, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+SELECT count(*) OVER(), id, created_at, name, email, password_hash, activated, locale, version
+FROM users
+WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+AND (email ILIKE '%%' || $2 || '%%' OR $2 = '')
+ORDER BY %s %s, id ASC
+LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	args := []interface{}{name, email, filters.limit(), filters.offset()}
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	users := []*User{}
+	for rows.Next() {
+		var user User
+		err := rows.Scan(
+			&totalRecords,
+			&user.ID,
+			&user.CreatedAt,
+			&user.Name,
+			&user.Email,
+			&user.Password.hash,
+			&user.Activated,
+			&user.Locale,
+			&user.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		users = append(users, &user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return users, metadata, nil
+}
 func (m UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-SELECT id, created_at, name, email, password_hash, activated, version
+SELECT id, created_at, name, email, password_hash, activated, locale, version
 FROM users
 WHERE email = $1`
 	var user User
@@ -114,6 +228,7 @@ WHERE email = $1`
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.Locale,
 		&user.Version,
 	)
 	if err != nil {
@@ -127,27 +242,40 @@ WHERE email = $1`
 	return &user, nil
 }
 func (m UserModel) Update(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return updateUser(ctx, m.DB, user)
+}
+
+// UpdateAudited saves the user and records entry in the audit log in the
+// same transaction.
+func (m UserModel) UpdateAudited(user *User, entry *AuditEntry) error {
+	return withAudit(m.DB, entry, func(ctx context.Context, tx *sql.Tx) error {
+		return updateUser(ctx, tx, user)
+	})
+}
+
+func updateUser(ctx context.Context, db queryer, user *User) error {
 	query := `
 UPDATE users
-SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
-WHERE id = $5 AND version = $6
+SET name = $1, email = $2, password_hash = $3, activated = $4, locale = $5, version = version + 1,
+	activated_at = CASE WHEN $4 THEN COALESCE(activated_at, NOW()) ELSE activated_at END
+WHERE id = $6 AND version = $7
 RETURNING version`
 	args := []interface{}{
 		user.Name,
 		user.Email,
 		user.Password.hash,
 		user.Activated,
+		user.Locale,
 		user.ID,
 		user.Version,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 			return ErrDuplicateEmail
-			return ErrDuplicateEmail
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
@@ -156,10 +284,27 @@ RETURNING version`
 	}
 	return nil
 }
+
+// DeleteUnactivated removes accounts that registered before the given time
+// and were never activated. activated_at is set the first time an account is
+// activated and kept when an admin deactivates it, so such accounts survive.
+func (m UserModel) DeleteUnactivated(createdBefore time.Time) (int64, error) {
+	query := `
+DELETE FROM users
+WHERE activated = false AND activated_at IS NULL AND created_at < $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := m.DB.ExecContext(ctx, query, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 	query := `
-SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.locale, users.version
 FROM users
 INNER JOIN tokens
 ON users.id = tokens.user_id
@@ -177,6 +322,7 @@ AND tokens.expiry > $3`
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.Locale,
 		&user.Version,
 	)
 	if err != nil {
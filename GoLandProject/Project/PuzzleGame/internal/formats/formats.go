@@ -0,0 +1,199 @@
+// Package formats converts standard puzzle file formats into puzzle items.
+package formats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	KindCrossword = "crossword"
+	KindSudoku    = "sudoku"
+	KindJigsaw    = "jigsaw"
+)
+
+// Block marks a black square in a crossword grid.
+const Block = "#"
+
+var (
+	ErrUnsupported = errors.New("unsupported puzzle")
+	ErrChecksum    = errors.New("checksum mismatch")
+)
+
+// Item is one playable puzzle read from a file. Content is a *Crossword, a
+// *Sudoku or a *Jigsaw depending on Kind.
+type Item struct {
+	Kind    string
+	Title   string
+	Content interface{}
+}
+
+// Crossword holds a crossword grid and its clues. Grid is indexed by row and
+// then column; each cell holds Block, the solution for that square, or an
+// empty string when the file carries no solution.
+type Crossword struct {
+	Width     int        `json:"width"`
+	Height    int        `json:"height"`
+	Grid      [][]string `json:"grid"`
+	Across    []Clue     `json:"across"`
+	Down      []Clue     `json:"down"`
+	Author    string     `json:"author,omitempty"`
+	Copyright string     `json:"copyright,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+}
+
+type Clue struct {
+	Number int    `json:"number"`
+	Row    int    `json:"row"`
+	Col    int    `json:"col"`
+	Text   string `json:"text"`
+}
+
+// Sudoku holds a 9x9 grid. Zero marks an empty square. Solution is only set
+// when the file provides one.
+type Sudoku struct {
+	Cells    [][]int `json:"cells"`
+	Solution [][]int `json:"solution,omitempty"`
+}
+
+func (c *Crossword) isBlock(row, col int) bool {
+	return row < 0 || col < 0 || row >= c.Height || col >= c.Width || c.Grid[row][col] == Block
+}
+
+// startsAcross and startsDown follow the usual numbering rule: a square gets
+// a number when it begins a run of at least two white squares.
+func (c *Crossword) startsAcross(row, col int) bool {
+	return !c.isBlock(row, col) && c.isBlock(row, col-1) && !c.isBlock(row, col+1)
+}
+
+func (c *Crossword) startsDown(row, col int) bool {
+	return !c.isBlock(row, col) && c.isBlock(row-1, col) && !c.isBlock(row+1, col)
+}
+
+// numberedCell is a square that starts at least one entry.
+type numberedCell struct {
+	number       int
+	row, col     int
+	across, down bool
+}
+
+func (c *Crossword) numbering() []numberedCell {
+	var cells []numberedCell
+	for row := 0; row < c.Height; row++ {
+		for col := 0; col < c.Width; col++ {
+			across, down := c.startsAcross(row, col), c.startsDown(row, col)
+			if across || down {
+				cells = append(cells, numberedCell{number: len(cells) + 1, row: row, col: col, across: across, down: down})
+			}
+		}
+	}
+	return cells
+}
+
+// Numbers returns the clue number of every square, zero for squares that do
+// not start an entry.
+func (c *Crossword) Numbers() [][]int {
+	numbers := make([][]int, c.Height)
+	for row := range numbers {
+		numbers[row] = make([]int, c.Width)
+	}
+	for _, cell := range c.numbering() {
+		numbers[cell.row][cell.col] = cell.number
+	}
+	return numbers
+}
+
+func validateSudoku(s *Sudoku) error {
+	if err := checkSudokuGrid(s.Cells); err != nil {
+		return err
+	}
+	if s.Solution == nil {
+		return nil
+	}
+	if err := checkSudokuGrid(s.Solution); err != nil {
+		return err
+	}
+	for row := range s.Cells {
+		for col, value := range s.Cells[row] {
+			if s.Solution[row][col] == 0 {
+				return errors.New("sudoku solution must fill every square")
+			}
+			if value != 0 && value != s.Solution[row][col] {
+				return errors.New("sudoku solution does not match the givens")
+			}
+		}
+	}
+	return nil
+}
+
+// checkSudokuGrid checks the shape of the grid and that no digit repeats in
+// a row, column or box.
+func checkSudokuGrid(cells [][]int) error {
+	if len(cells) != 9 {
+		return errors.New("sudoku must have 9 rows")
+	}
+	var rows, cols, boxes [9][10]bool
+	for row := range cells {
+		if len(cells[row]) != 9 {
+			return errors.New("sudoku must have 9 columns")
+		}
+		for col, value := range cells[row] {
+			if value < 0 || value > 9 {
+				return errors.New("sudoku squares must hold digits 1 to 9")
+			}
+			if value == 0 {
+				continue
+			}
+			box := row/3*3 + col/3
+			if rows[row][value] || cols[col][value] || boxes[box][value] {
+				return errors.New("sudoku repeats a digit in a row, column or box")
+			}
+			rows[row][value], cols[col][value], boxes[box][value] = true, true, true
+		}
+	}
+	return nil
+}
+
+// DecodeItem rebuilds an item from its kind and the JSON encoding of its
+// content, as stored alongside a puzzle pack.
+func DecodeItem(kind, title string, content []byte) (*Item, error) {
+	item := &Item{Kind: kind, Title: title}
+	switch kind {
+	case KindCrossword:
+		var crossword Crossword
+		if err := json.Unmarshal(content, &crossword); err != nil {
+			return nil, err
+		}
+		if len(crossword.Grid) != crossword.Height {
+			return nil, errors.New("crossword grid does not match its height")
+		}
+		for _, row := range crossword.Grid {
+			if len(row) != crossword.Width {
+				return nil, errors.New("crossword grid does not match its width")
+			}
+		}
+		item.Content = &crossword
+	case KindSudoku:
+		var sudoku Sudoku
+		if err := json.Unmarshal(content, &sudoku); err != nil {
+			return nil, err
+		}
+		if err := validateSudoku(&sudoku); err != nil {
+			return nil, err
+		}
+		item.Content = &sudoku
+	case KindJigsaw:
+		var jigsaw Jigsaw
+		if err := json.Unmarshal(content, &jigsaw); err != nil {
+			return nil, err
+		}
+		if jigsaw.Image == "" || len(jigsaw.Pieces) != jigsaw.Rows*jigsaw.Cols {
+			return nil, errors.New("jigsaw pieces do not match its grid")
+		}
+		item.Content = &jigsaw
+	default:
+		return nil, fmt.Errorf("%w: kind %q", ErrUnsupported, kind)
+	}
+	return item, nil
+}
@@ -0,0 +1,203 @@
+package formats
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// buildPuz assembles a .puz file with correct checksums from a solution grid
+// written row by row, '.' marking blocks.
+func buildPuz(title string, rows []string, clues []string) []byte {
+	width, height := len(rows[0]), len(rows)
+	solution := []byte(strings.Join(rows, ""))
+	state := make([]byte, len(solution))
+	for i, c := range solution {
+		state[i] = '-'
+		if c == '.' {
+			state[i] = '.'
+		}
+	}
+	header := make([]byte, puzHeaderSize)
+	copy(header[puzMagicOffset:], puzMagic)
+	copy(header[puzVersionOffset:], "1.3\x00")
+	header[puzWidthOffset] = byte(width)
+	header[puzHeightOffset] = byte(height)
+	binary.LittleEndian.PutUint16(header[puzClueCountOffset:], uint16(len(clues)))
+	binary.LittleEndian.PutUint16(header[puzClueCountOffset+2:], 1)
+
+	rawClues := make([][]byte, len(clues))
+	for i, clue := range clues {
+		rawClues[i] = []byte(clue)
+	}
+	cib := puzChecksum(header[puzCIBStart:puzCIBStop], 0)
+	text := puzTextChecksum([]byte(title), nil, nil, rawClues, nil, "1.3", 0)
+	overall := puzTextChecksum([]byte(title), nil, nil, rawClues, nil, "1.3",
+		puzChecksum(state, puzChecksum(solution, cib)))
+	binary.LittleEndian.PutUint16(header[puzChecksumOffset:], overall)
+	binary.LittleEndian.PutUint16(header[puzCIBChecksumOffset:], cib)
+	masked := puzMaskedChecksums(cib, puzChecksum(solution, 0), puzChecksum(state, 0), text)
+	copy(header[puzMaskedLowOffset:], masked[:])
+
+	b := append(header, solution...)
+	b = append(b, state...)
+	for _, s := range append([]string{title, "", ""}, clues...) {
+		b = append(append(b, s...), 0)
+	}
+	return append(b, 0)
+}
+
+var puzSeed = buildPuz("Tiny", []string{"CAT", "A.O", "BEE"}, []string{"Pet", "Taxi", "Digit", "Insect"})
+
+func TestParsePuz(t *testing.T) {
+	item, err := ParsePuz(puzSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crossword := item.Content.(*Crossword)
+	if item.Title != "Tiny" || len(crossword.Across) != 2 || len(crossword.Down) != 2 {
+		t.Fatalf("unexpected crossword %+v", crossword)
+	}
+	if crossword.Grid[1][1] != Block || crossword.Down[1].Text != "Digit" {
+		t.Fatalf("clues assigned wrongly: %+v", crossword)
+	}
+
+	damaged := append([]byte(nil), puzSeed...)
+	damaged[puzHeaderSize] = 'X'
+	if _, err := ParsePuz(damaged); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+}
+
+func TestParseSudokuStrings(t *testing.T) {
+	items, err := ParseSudokuStrings([]byte(sudokuSeed + "\n# comment\n" + strings.Repeat(".", 81) + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Content.(*Sudoku).Cells[0][0] != 5 {
+		t.Fatalf("unexpected items %+v", items)
+	}
+	if _, err := ParseSudokuStrings([]byte("11" + strings.Repeat("0", 79))); err == nil {
+		t.Fatal("expected error for repeated digit")
+	}
+}
+
+const sudokuSeed = "530070000600195000098000060800060003400803001700020006060000280000419005000080079"
+
+const ipuzSeed = `{
+	"version": "http://ipuz.org/v2",
+	"kind": ["http://ipuz.org/crossword#1"],
+	"title": "Tiny",
+	"dimensions": {"width": 3, "height": 3},
+	"puzzle": [[1, 0, 2], [0, "#", 0], [3, 0, 0]],
+	"solution": [["C", "A", "T"], ["A", "#", "O"], ["B", "E", "E"]],
+	"clues": {
+		"Across": [[1, "Pet"], [3, "Insect"]],
+		"Down": [{"number": 1, "clue": "Taxi"}, [2, "Digit"]]
+	}
+}`
+
+func TestParseIPUZ(t *testing.T) {
+	item, err := ParseIPUZ([]byte("ipuz(" + ipuzSeed + ")"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crossword := item.Content.(*Crossword)
+	if crossword.Grid[2][1] != "E" || len(crossword.Across) != 2 || crossword.Down[0].Text != "Taxi" {
+		t.Fatalf("unexpected crossword %+v", crossword)
+	}
+}
+
+// checkItem asserts the invariants every parser promises for its output.
+func checkItem(t *testing.T, item *Item) {
+	switch content := item.Content.(type) {
+	case *Crossword:
+		if len(content.Grid) != content.Height {
+			t.Fatalf("grid has %d rows, want %d", len(content.Grid), content.Height)
+		}
+		for _, row := range content.Grid {
+			if len(row) != content.Width {
+				t.Fatalf("grid row has %d squares, want %d", len(row), content.Width)
+			}
+		}
+		for _, clue := range append(content.Across, content.Down...) {
+			if content.isBlock(clue.Row, clue.Col) {
+				t.Fatalf("clue %d starts on a block", clue.Number)
+			}
+		}
+		content.Numbers()
+	case *Sudoku:
+		if err := validateSudoku(content); err != nil {
+			t.Fatalf("parser returned invalid sudoku: %v", err)
+		}
+	default:
+		t.Fatalf("unexpected content %T", item.Content)
+	}
+}
+
+func FuzzParsePuz(f *testing.F) {
+	f.Add(puzSeed)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		item, err := ParsePuz(b)
+		if err == nil {
+			checkItem(t, item)
+		}
+	})
+}
+
+func FuzzParseIPUZ(f *testing.F) {
+	f.Add([]byte(ipuzSeed))
+	f.Add([]byte(`{"version": "http://ipuz.org/v2", "kind": ["http://ipuz.org/sudoku#1"], "puzzle": [[5, 3, 0, 0, 7, 0, 0, 0, 0]]}`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		item, err := ParseIPUZ(b)
+		if err == nil {
+			checkItem(t, item)
+		}
+	})
+}
+
+func FuzzParseSudokuStrings(f *testing.F) {
+	f.Add([]byte(sudokuSeed))
+	f.Add([]byte("53..7....\n6..195...\n.98....6.\n8...6...3\n4..8.3..1\n7...2...6\n.6....28.\n...419..5\n....8..79\n"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		items, err := ParseSudokuStrings(b)
+		if err == nil {
+			for _, item := range items {
+				checkItem(t, item)
+			}
+		}
+	})
+}
+
+func TestCutJigsaw(t *testing.T) {
+	jigsaw, err := CutJigsaw(1600, 900, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jigsaw.Pieces) != jigsaw.Rows*jigsaw.Cols || jigsaw.Rows*jigsaw.Cols < 90 || jigsaw.Rows*jigsaw.Cols > 110 {
+		t.Fatalf("unexpected grid %dx%d", jigsaw.Rows, jigsaw.Cols)
+	}
+	area := 0
+	for _, piece := range jigsaw.Pieces {
+		area += piece.Width * piece.Height
+		if piece.Col+1 < jigsaw.Cols {
+			right := jigsaw.Pieces[piece.Row*jigsaw.Cols+piece.Col+1]
+			if piece.Edges[1] == 0 || piece.Edges[1] != -right.Edges[3] {
+				t.Fatalf("pieces %d,%d and %d,%d do not fit", piece.Row, piece.Col, right.Row, right.Col)
+			}
+		}
+		if piece.Row+1 < jigsaw.Rows {
+			below := jigsaw.Pieces[(piece.Row+1)*jigsaw.Cols+piece.Col]
+			if piece.Edges[2] == 0 || piece.Edges[2] != -below.Edges[0] {
+				t.Fatalf("pieces %d,%d and %d,%d do not fit", piece.Row, piece.Col, below.Row, below.Col)
+			}
+		}
+	}
+	if area != 1600*900 {
+		t.Fatalf("pieces cover %d pixels, want %d", area, 1600*900)
+	}
+	if _, err := CutJigsaw(40, 40, 100, 1); err == nil {
+		t.Fatal("expected error for an image that is too small")
+	}
+}
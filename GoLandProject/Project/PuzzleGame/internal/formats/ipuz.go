@@ -0,0 +1,237 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ipuzDocument struct {
+	Version    string                       `json:"version"`
+	Kind       []string                     `json:"kind"`
+	Title      string                       `json:"title"`
+	Author     string                       `json:"author"`
+	Copyright  string                       `json:"copyright"`
+	Notes      string                       `json:"notes"`
+	Block      *string                      `json:"block"`
+	Dimensions struct{ Width, Height int }  `json:"dimensions"`
+	Puzzle     [][]json.RawMessage          `json:"puzzle"`
+	Solution   [][]json.RawMessage          `json:"solution"`
+	Clues      map[string][]json.RawMessage `json:"clues"`
+}
+
+// ParseIPUZ reads an ipuz document holding a crossword or a sudoku. The
+// specification allows ipuz files to be wrapped in "ipuz(...)" for JSONP,
+// which is accepted too.
+func ParseIPUZ(b []byte) (*Item, error) {
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("ipuz(")) && bytes.HasSuffix(b, []byte(")")) {
+		b = b[len("ipuz(") : len(b)-1]
+	}
+	var doc ipuzDocument
+	err := json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ipuz document: %w", err)
+	}
+	if !strings.HasPrefix(doc.Version, "http://ipuz.org/v") {
+		return nil, errors.New("invalid ipuz document: missing version")
+	}
+	for _, kind := range doc.Kind {
+		switch {
+		case strings.HasPrefix(kind, "http://ipuz.org/crossword"):
+			return parseIPUZCrossword(&doc)
+		case strings.HasPrefix(kind, "http://ipuz.org/sudoku"):
+			return parseIPUZSudoku(&doc)
+		}
+	}
+	return nil, fmt.Errorf("%w: ipuz kind %v", ErrUnsupported, doc.Kind)
+}
+
+func parseIPUZCrossword(doc *ipuzDocument) (*Item, error) {
+	width, height := doc.Dimensions.Width, doc.Dimensions.Height
+	if width <= 0 || height <= 0 || width > 255 || height > 255 {
+		return nil, errors.New("invalid ipuz document: bad dimensions")
+	}
+	if len(doc.Puzzle) != height {
+		return nil, errors.New("invalid ipuz document: puzzle does not match dimensions")
+	}
+	block := "#"
+	if doc.Block != nil {
+		block = *doc.Block
+	}
+	crossword := &Crossword{
+		Width:     width,
+		Height:    height,
+		Grid:      make([][]string, height),
+		Author:    doc.Author,
+		Copyright: doc.Copyright,
+		Notes:     doc.Notes,
+	}
+	positions := make(map[int][2]int)
+	for row := 0; row < height; row++ {
+		if len(doc.Puzzle[row]) != width {
+			return nil, errors.New("invalid ipuz document: puzzle does not match dimensions")
+		}
+		crossword.Grid[row] = make([]string, width)
+		for col, raw := range doc.Puzzle[row] {
+			value, number := ipuzCell(raw)
+			switch {
+			case value == block || value == "null":
+				crossword.Grid[row][col] = Block
+			case number > 0:
+				positions[number] = [2]int{row, col}
+			}
+		}
+	}
+	if doc.Solution != nil {
+		if len(doc.Solution) != height {
+			return nil, errors.New("invalid ipuz document: solution does not match dimensions")
+		}
+		for row := 0; row < height; row++ {
+			if len(doc.Solution[row]) != width {
+				return nil, errors.New("invalid ipuz document: solution does not match dimensions")
+			}
+			for col, raw := range doc.Solution[row] {
+				value, _ := ipuzCell(raw)
+				if crossword.Grid[row][col] != Block && value != block && value != "null" {
+					crossword.Grid[row][col] = value
+				}
+			}
+		}
+	}
+	// Fall back to standard numbering for grids that do not number their
+	// squares themselves.
+	if len(positions) == 0 {
+		for _, cell := range crossword.numbering() {
+			positions[cell.number] = [2]int{cell.row, cell.col}
+		}
+	}
+	for direction, clues := range doc.Clues {
+		for _, raw := range clues {
+			clue, err := ipuzClue(raw)
+			if err != nil {
+				return nil, err
+			}
+			position, ok := positions[clue.Number]
+			if !ok {
+				return nil, fmt.Errorf("invalid ipuz document: clue %d is not in the grid", clue.Number)
+			}
+			clue.Row, clue.Col = position[0], position[1]
+			switch strings.SplitN(direction, ":", 2)[0] {
+			case "Across":
+				crossword.Across = append(crossword.Across, clue)
+			case "Down":
+				crossword.Down = append(crossword.Down, clue)
+			}
+		}
+	}
+	return &Item{Kind: KindCrossword, Title: doc.Title, Content: crossword}, nil
+}
+
+func parseIPUZSudoku(doc *ipuzDocument) (*Item, error) {
+	sudoku := &Sudoku{}
+	var err error
+	sudoku.Cells, err = ipuzDigits(doc.Puzzle)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Solution != nil {
+		sudoku.Solution, err = ipuzDigits(doc.Solution)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := validateSudoku(sudoku); err != nil {
+		return nil, err
+	}
+	return &Item{Kind: KindSudoku, Title: doc.Title, Content: sudoku}, nil
+}
+
+func ipuzDigits(grid [][]json.RawMessage) ([][]int, error) {
+	cells := make([][]int, len(grid))
+	for row := range grid {
+		cells[row] = make([]int, len(grid[row]))
+		for col, raw := range grid[row] {
+			value, number := ipuzCell(raw)
+			if number == 0 && value != "" && value != "0" && value != "null" {
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, errors.New("invalid ipuz document: sudoku squares must hold digits")
+				}
+				number = n
+			}
+			cells[row][col] = number
+		}
+	}
+	return cells, nil
+}
+
+// ipuzCell decodes a cell, which may be a bare number or string, null, or an
+// object with the value under "cell" (puzzle) or "value" (solution). It
+// returns the cell as text and, when it is a positive number, as a number.
+func ipuzCell(raw json.RawMessage) (string, int) {
+	var object struct {
+		Cell  json.RawMessage `json:"cell"`
+		Value json.RawMessage `json:"value"`
+	}
+	if len(raw) > 0 && raw[0] == '{' && json.Unmarshal(raw, &object) == nil {
+		switch {
+		case object.Cell != nil:
+			raw = object.Cell
+		case object.Value != nil:
+			raw = object.Value
+		default:
+			return "", 0
+		}
+	}
+	if string(bytes.TrimSpace(raw)) == "null" {
+		return "null", 0
+	}
+	var number int
+	if json.Unmarshal(raw, &number) == nil {
+		if number < 0 {
+			number = 0
+		}
+		return strconv.Itoa(number), number
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text, 0
+	}
+	return strings.TrimSpace(string(raw)), 0
+}
+
+// ipuzClue decodes a clue given either as [number, "text"] or as an object
+// with number and clue fields.
+func ipuzClue(raw json.RawMessage) (Clue, error) {
+	var pair []json.RawMessage
+	if json.Unmarshal(raw, &pair) == nil && len(pair) >= 2 {
+		var clue Clue
+		number, _ := ipuzCell(pair[0])
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return Clue{}, errors.New("invalid ipuz document: bad clue number")
+		}
+		clue.Number = n
+		if err := json.Unmarshal(pair[1], &clue.Text); err != nil {
+			return Clue{}, errors.New("invalid ipuz document: bad clue text")
+		}
+		return clue, nil
+	}
+	var object struct {
+		Number json.RawMessage `json:"number"`
+		Clue   string          `json:"clue"`
+	}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return Clue{}, errors.New("invalid ipuz document: bad clue")
+	}
+	number, _ := ipuzCell(object.Number)
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return Clue{}, errors.New("invalid ipuz document: bad clue number")
+	}
+	return Clue{Number: n, Text: object.Clue}, nil
+}
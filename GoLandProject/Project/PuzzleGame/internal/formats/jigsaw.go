@@ -0,0 +1,163 @@
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+const (
+	MinJigsawPieces = 4
+	MaxJigsawPieces = 2000
+
+	// minPieceSize is the smallest side of a piece in image pixels.
+	minPieceSize = 16
+)
+
+// Jigsaw describes how an uploaded image is cut into pieces. The image itself
+// lives in blob storage under Image; all coordinates are image pixels.
+type Jigsaw struct {
+	Image  string  `json:"image"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Rows   int     `json:"rows"`
+	Cols   int     `json:"cols"`
+	Pieces []Piece `json:"pieces"`
+}
+
+// Piece is one jigsaw piece. X, Y, Width and Height give the cell of the grid
+// it was cut from, Edges its top, right, bottom and left sides (0 for a flat
+// border, 1 for a tab, -1 for a blank) and Path its outline as SVG path data.
+type Piece struct {
+	Row    int    `json:"row"`
+	Col    int    `json:"col"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Edges  [4]int `json:"edges"`
+	Path   string `json:"path"`
+}
+
+// CutJigsaw cuts an image of the given size into a grid of roughly the
+// requested number of pieces, choosing rows and columns so that pieces are
+// close to square. The tabs are chosen at random from seed, so the same seed
+// always gives the same cut.
+func CutJigsaw(width, height, pieces int, seed int64) (*Jigsaw, error) {
+	if pieces < MinJigsawPieces || pieces > MaxJigsawPieces {
+		return nil, fmt.Errorf("piece count must be between %d and %d", MinJigsawPieces, MaxJigsawPieces)
+	}
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("image must not be empty")
+	}
+	cols := int(math.Round(math.Sqrt(float64(pieces) * float64(width) / float64(height))))
+	cols = max(cols, 1)
+	rows := max(int(math.Round(float64(pieces)/float64(cols))), 1)
+	if width/cols < minPieceSize || height/rows < minPieceSize {
+		return nil, errors.New("image is too small for that many pieces")
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	// horizontal[r][c] is the edge below row r, vertical[r][c] the edge right
+	// of column c, each seen from the piece above or to the left of it.
+	horizontal := make([][]int, rows-1)
+	for r := range horizontal {
+		horizontal[r] = randomEdges(rng, cols)
+	}
+	vertical := make([][]int, rows)
+	for r := range vertical {
+		vertical[r] = randomEdges(rng, cols-1)
+	}
+
+	jigsaw := &Jigsaw{Width: width, Height: height, Rows: rows, Cols: cols}
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			piece := Piece{
+				Row:    r,
+				Col:    c,
+				X:      c * width / cols,
+				Y:      r * height / rows,
+				Width:  (c+1)*width/cols - c*width/cols,
+				Height: (r+1)*height/rows - r*height/rows,
+			}
+			if r > 0 {
+				piece.Edges[0] = -horizontal[r-1][c]
+			}
+			if c < cols-1 {
+				piece.Edges[1] = vertical[r][c]
+			}
+			if r < rows-1 {
+				piece.Edges[2] = horizontal[r][c]
+			}
+			if c > 0 {
+				piece.Edges[3] = -vertical[r][c-1]
+			}
+			piece.Path = piecePath(&piece)
+			jigsaw.Pieces = append(jigsaw.Pieces, piece)
+		}
+	}
+	return jigsaw, nil
+}
+
+func randomEdges(rng *rand.Rand, n int) []int {
+	edges := make([]int, n)
+	for i := range edges {
+		edges[i] = 1
+		if rng.Intn(2) == 0 {
+			edges[i] = -1
+		}
+	}
+	return edges
+}
+
+// tabCurve is the outline of a tab as cubic Bézier segments, in units of the
+// edge length along the edge and of the shorter piece side across it.
+var tabCurve = [][3][2]float64{
+	{{0.40, 0.00}, {0.32, 0.22}, {0.50, 0.22}},
+	{{0.68, 0.22}, {0.60, 0.00}, {0.65, 0.00}},
+}
+
+// piecePath traces the piece clockwise from its top-left corner.
+func piecePath(p *Piece) string {
+	corners := [5][2]float64{
+		{float64(p.X), float64(p.Y)},
+		{float64(p.X + p.Width), float64(p.Y)},
+		{float64(p.X + p.Width), float64(p.Y + p.Height)},
+		{float64(p.X), float64(p.Y + p.Height)},
+		{float64(p.X), float64(p.Y)},
+	}
+	depth := float64(min(p.Width, p.Height))
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "M%.1f %.1f", corners[0][0], corners[0][1])
+	for side, edge := range p.Edges {
+		from, to := corners[side], corners[side+1]
+		if edge != 0 {
+			dx, dy := to[0]-from[0], to[1]-from[1]
+			length := math.Hypot(dx, dy)
+			// Clockwise in image coordinates, the outward normal of the
+			// direction (dx, dy) is (dy, -dx).
+			nx, ny := dy/length, -dx/length
+			point := func(u, v float64) (float64, float64) {
+				v *= depth * float64(edge)
+				return from[0] + dx*u + nx*v, from[1] + dy*u + ny*v
+			}
+			x, y := point(0.35, 0)
+			fmt.Fprintf(&sb, " L%.1f %.1f", x, y)
+			for _, segment := range tabCurve {
+				sb.WriteString(" C")
+				for i, control := range segment {
+					x, y := point(control[0], control[1])
+					if i > 0 {
+						sb.WriteByte(' ')
+					}
+					fmt.Fprintf(&sb, "%.1f %.1f", x, y)
+				}
+			}
+		}
+		fmt.Fprintf(&sb, " L%.1f %.1f", to[0], to[1])
+	}
+	sb.WriteString(" Z")
+	return sb.String()
+}
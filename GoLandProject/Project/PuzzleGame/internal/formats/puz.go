@@ -0,0 +1,202 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Offsets into the fixed-size header of an Across Lite .puz file.
+const (
+	puzChecksumOffset       = 0x00
+	puzMagicOffset          = 0x02
+	puzCIBChecksumOffset    = 0x0E
+	puzMaskedLowOffset      = 0x10
+	puzMaskedHighOffset     = 0x14
+	puzVersionOffset        = 0x18
+	puzWidthOffset          = 0x2C
+	puzHeightOffset         = 0x2D
+	puzClueCountOffset      = 0x2E
+	puzScrambledOffset      = 0x32
+	puzHeaderSize           = 0x34
+	puzCIBStart, puzCIBStop = 0x2C, 0x34
+)
+
+var puzMagic = []byte("ACROSS&DOWN\x00")
+
+// ParsePuz reads an Across Lite .puz file. All four kinds of checksum stored
+// in the file are verified, so a damaged or hand-edited file is rejected with
+// ErrChecksum instead of producing a broken grid. Scrambled puzzles are not
+// supported because their solution cannot be recovered without the key.
+func ParsePuz(b []byte) (*Item, error) {
+	if len(b) < puzHeaderSize || !bytes.Equal(b[puzMagicOffset:puzMagicOffset+len(puzMagic)], puzMagic) {
+		return nil, errors.New("not an Across Lite puzzle file")
+	}
+	width := int(b[puzWidthOffset])
+	height := int(b[puzHeightOffset])
+	clueCount := int(binary.LittleEndian.Uint16(b[puzClueCountOffset:]))
+	if width == 0 || height == 0 {
+		return nil, errors.New("puzzle grid must not be empty")
+	}
+	if binary.LittleEndian.Uint16(b[puzScrambledOffset:]) != 0 {
+		return nil, fmt.Errorf("%w: scrambled puzzles are not supported", ErrUnsupported)
+	}
+	size := width * height
+	if len(b) < puzHeaderSize+2*size {
+		return nil, errors.New("puzzle file is truncated")
+	}
+	solution := b[puzHeaderSize : puzHeaderSize+size]
+	state := b[puzHeaderSize+size : puzHeaderSize+2*size]
+
+	rest := b[puzHeaderSize+2*size:]
+	readString := func() (string, []byte, error) {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			return "", nil, errors.New("puzzle file is truncated")
+		}
+		raw := rest[:i]
+		rest = rest[i+1:]
+		return latin1(raw), raw, nil
+	}
+	title, rawTitle, err := readString()
+	if err != nil {
+		return nil, err
+	}
+	author, rawAuthor, err := readString()
+	if err != nil {
+		return nil, err
+	}
+	copyright, rawCopyright, err := readString()
+	if err != nil {
+		return nil, err
+	}
+	clues := make([]string, clueCount)
+	rawClues := make([][]byte, clueCount)
+	for i := range clues {
+		clues[i], rawClues[i], err = readString()
+		if err != nil {
+			return nil, err
+		}
+	}
+	notes, rawNotes, err := readString()
+	if err != nil {
+		return nil, err
+	}
+
+	version := string(bytes.TrimRight(b[puzVersionOffset:puzVersionOffset+4], "\x00"))
+	cib := puzChecksum(b[puzCIBStart:puzCIBStop], 0)
+	text := puzTextChecksum(rawTitle, rawAuthor, rawCopyright, rawClues, rawNotes, version, 0)
+	overall := puzTextChecksum(rawTitle, rawAuthor, rawCopyright, rawClues, rawNotes, version,
+		puzChecksum(state, puzChecksum(solution, cib)))
+	if binary.LittleEndian.Uint16(b[puzCIBChecksumOffset:]) != cib ||
+		binary.LittleEndian.Uint16(b[puzChecksumOffset:]) != overall {
+		return nil, ErrChecksum
+	}
+	masked := puzMaskedChecksums(cib, puzChecksum(solution, 0), puzChecksum(state, 0), text)
+	if !bytes.Equal(b[puzMaskedLowOffset:puzMaskedLowOffset+8], masked[:]) {
+		return nil, ErrChecksum
+	}
+
+	crossword := &Crossword{
+		Width:     width,
+		Height:    height,
+		Grid:      make([][]string, height),
+		Author:    author,
+		Copyright: copyright,
+		Notes:     notes,
+	}
+	for row := 0; row < height; row++ {
+		crossword.Grid[row] = make([]string, width)
+		for col := 0; col < width; col++ {
+			cell := solution[row*width+col]
+			switch {
+			case cell == '.':
+				crossword.Grid[row][col] = Block
+			case cell == '-':
+				crossword.Grid[row][col] = ""
+			default:
+				crossword.Grid[row][col] = latin1([]byte{cell})
+			}
+		}
+	}
+
+	// Clues are stored in numbering order, with the across clue before the
+	// down clue when a square starts both.
+	next := 0
+	for _, cell := range crossword.numbering() {
+		for _, across := range []bool{true, false} {
+			if (across && !cell.across) || (!across && !cell.down) {
+				continue
+			}
+			if next >= len(clues) {
+				return nil, errors.New("puzzle file has fewer clues than the grid needs")
+			}
+			clue := Clue{Number: cell.number, Row: cell.row, Col: cell.col, Text: clues[next]}
+			next++
+			if across {
+				crossword.Across = append(crossword.Across, clue)
+			} else {
+				crossword.Down = append(crossword.Down, clue)
+			}
+		}
+	}
+	if next != len(clues) {
+		return nil, errors.New("puzzle file has more clues than the grid needs")
+	}
+	return &Item{Kind: KindCrossword, Title: title, Content: crossword}, nil
+}
+
+// puzChecksum is the rotating 16-bit checksum used throughout the format.
+func puzChecksum(b []byte, sum uint16) uint16 {
+	for _, c := range b {
+		if sum&1 != 0 {
+			sum = sum>>1 + 0x8000
+		} else {
+			sum >>= 1
+		}
+		sum += uint16(c)
+	}
+	return sum
+}
+
+// puzTextChecksum covers the strings section. Title, author, copyright and
+// notes are included with their terminating NUL and only when non-empty,
+// clues without it; notes only count from version 1.3 on.
+func puzTextChecksum(title, author, copyright []byte, clues [][]byte, notes []byte, version string, sum uint16) uint16 {
+	for _, s := range [][]byte{title, author, copyright} {
+		if len(s) > 0 {
+			sum = puzChecksum(append(s[:len(s):len(s)], 0), sum)
+		}
+	}
+	for _, clue := range clues {
+		sum = puzChecksum(clue, sum)
+	}
+	if len(notes) > 0 && version >= "1.3" {
+		sum = puzChecksum(append(notes[:len(notes):len(notes)], 0), sum)
+	}
+	return sum
+}
+
+// puzMaskedChecksums returns the eight masked checksum bytes, low bytes first,
+// each XORed with a letter of "ICHEATED".
+func puzMaskedChecksums(cib, solution, state, text uint16) [8]byte {
+	sums := [4]uint16{cib, solution, state, text}
+	mask := []byte("ICHEATED")
+	var masked [8]byte
+	for i, sum := range sums {
+		masked[i] = mask[i] ^ byte(sum)
+		masked[i+4] = mask[i+4] ^ byte(sum>>8)
+	}
+	return masked
+}
+
+// latin1 decodes ISO-8859-1, the encoding of strings in .puz files.
+func latin1(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		sb.WriteRune(rune(c))
+	}
+	return sb.String()
+}
@@ -0,0 +1,61 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseSudokuStrings reads sudokus written as strings of 81 squares, the
+// format most sudoku collections are shared in. Digits 1 to 9 are givens and
+// '0', '.' or '_' mark an empty square. Whitespace and '|' are ignored, so a
+// file may hold one puzzle per line or a puzzle laid out over nine lines, and
+// lines starting with '#' are comments.
+func ParseSudokuStrings(b []byte) ([]*Item, error) {
+	var items []*Item
+	var pending []int
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		for _, r := range text {
+			switch {
+			case r >= '1' && r <= '9':
+				pending = append(pending, int(r-'0'))
+			case r == '0' || r == '.' || r == '_':
+				pending = append(pending, 0)
+			case r == ' ' || r == '\t' || r == '|':
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: unexpected character %q", line, r)
+			}
+			if len(pending) == 81 {
+				sudoku := &Sudoku{Cells: make([][]int, 9)}
+				for row := range sudoku.Cells {
+					sudoku.Cells[row] = pending[row*9 : row*9+9]
+				}
+				if err := validateSudoku(sudoku); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				items = append(items, &Item{Kind: KindSudoku, Title: fmt.Sprintf("Sudoku %d", len(items)+1), Content: sudoku})
+				pending = nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pending) != 0 {
+		return nil, fmt.Errorf("line %d: sudoku must have 81 squares", line)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("file does not contain any sudoku")
+	}
+	return items, nil
+}
@@ -0,0 +1,234 @@
+package i18n
+
+// catalogue maps each locale to its messages. English is the reference: every
+// key must be present there, other locales may lag behind.
+var catalogue = map[string]map[string]string{
+	English: {
+		"error.server":                  "the server encountered a problem and could not process your request",
+		"error.not_found":               "the requested resource could not be found",
+		"error.method_not_allowed":      "the %s method is not supported for this resource",
+		"error.edit_conflict":           "unable to update the record due to an edit conflict, please try again",
+		"error.precondition_failed":     "the resource has been modified since you last fetched it, please fetch it again",
+		"error.invalid_transition":      "unable to move the puzzle from %s to %s",
+		"error.rate_limit":              "rate limit exceeded",
+		"error.invalid_credentials":     "invalid authentication credentials",
+		"error.invalid_token":           "invalid or missing authentication token",
+		"error.authentication_required": "you must be authenticated to access this resource",
+		"error.inactive_account":        "your user account must be activated to access this resource",
+		"error.not_permitted":           "your user account doesn't have the necessary permissions to access this resource",
+		"error.job_not_retryable":       "only dead jobs can be retried",
+		"error.email_not_retryable":     "only failed emails can be retried",
+		"error.import_rows_invalid":     "no puzzles were imported because some rows are invalid",
+
+		"message.puzzle_trashed":           "puzzle successfully moved to trash",
+		"message.unsubscribed":             "you will no longer receive these emails",
+		"message.confirm_unsubscribe":      "send a POST request to this link to stop receiving these emails",
+		"message.review_deleted":           "review successfully deleted",
+		"message.favorite_removed":         "puzzle removed from favorites",
+		"message.collection_deleted":       "collection successfully deleted",
+		"message.collection_entry_removed": "entry removed from the collection",
+
+		"validation.required":                "must be provided",
+		"validation.max_bytes":               "must not be more than %d bytes long",
+		"validation.min_bytes":               "must be at least %d bytes long",
+		"validation.exact_bytes":             "must be %d bytes long",
+		"validation.positive":                "must be a positive integer",
+		"validation.greater_than_zero":       "must be greater than zero",
+		"validation.max_value":               "must be a maximum of %d",
+		"validation.max_page":                "must be a maximum of 10 million",
+		"validation.between":                 "must be between %d and %d",
+		"validation.one_of":                  "must be one of: %s",
+		"validation.integer":                 "must be an integer value",
+		"validation.json_type":               "has an incorrect JSON type",
+		"validation.num_of_puzzles_format":   "must be in the format \"<n> puzzles\"",
+		"validation.number":                  "must be a number",
+		"validation.boolean":                 "must be a boolean value",
+		"validation.duplicates":              "must not contain duplicate values",
+		"validation.invalid":                 "is invalid: %v",
+		"validation.unreadable":              "could not be read: %v",
+		"validation.email":                   "must be a valid email address",
+		"validation.email_taken":             "a user with this email address already exists",
+		"validation.activation_token":        "invalid or expired activation token",
+		"validation.unsubscribe_token":       "invalid unsubscribe link",
+		"validation.min_genres":              "must contain at least 1 genre",
+		"validation.max_genres":              "must not contain more than %d genres",
+		"validation.min_roles":               "must contain at least 1 role",
+		"validation.unknown_roles":           "must only contain known roles",
+		"validation.min_codes":               "must contain at least 1 permission code",
+		"validation.unknown_codes":           "must only contain known permission codes",
+		"validation.unknown_genres":          "must only contain known genres, not: %s",
+		"validation.genre_taken":             "is already used by another genre",
+		"validation.slug":                    "must only contain lower case letters and digits separated by single dashes",
+		"validation.sort":                    "invalid sort value",
+		"validation.cursor":                  "must be a valid cursor",
+		"validation.cursor_with_page":        "must not be combined with cursor",
+		"validation.cursor_sort":             "must be used with the same sort value it was issued for",
+		"validation.status":                  "invalid status value",
+		"validation.job_status":              "invalid job status",
+		"validation.email_status":            "invalid email status",
+		"validation.published_only":          "only published puzzles can be listed",
+		"validation.image_type":              "must be a PNG, JPEG or GIF image",
+		"validation.image_decode":            "could not be decoded",
+		"validation.image_pixels":            "must not be larger than %d megapixels",
+		"validation.no_solution":             "is not available for this item",
+		"validation.not_printable":           "cannot be rendered for printing",
+		"validation.pdf_text":                "cannot be used for this item, its text is not Latin-1; use svg",
+		"validation.review_exists":           "you have already reviewed this puzzle",
+		"validation.review_published":        "only published puzzles can be reviewed",
+		"validation.review_own":              "you cannot review your own puzzle",
+		"validation.not_found":               "does not exist",
+		"validation.collection_entry_exists": "is already in this collection",
+	},
+	Russian: {
+		"error.server":                  "на сервере возникла проблема, и он не смог обработать ваш запрос",
+		"error.not_found":               "запрошенный ресурс не найден",
+		"error.method_not_allowed":      "метод %s не поддерживается для этого ресурса",
+		"error.edit_conflict":           "не удалось обновить запись из-за конфликта изменений, попробуйте ещё раз",
+		"error.precondition_failed":     "ресурс изменился с момента последнего запроса, запросите его заново",
+		"error.invalid_transition":      "невозможно перевести головоломку из статуса %s в %s",
+		"error.rate_limit":              "превышен лимит запросов",
+		"error.invalid_credentials":     "неверные учётные данные",
+		"error.invalid_token":           "неверный или отсутствующий токен аутентификации",
+		"error.authentication_required": "для доступа к этому ресурсу необходимо пройти аутентификацию",
+		"error.inactive_account":        "для доступа к этому ресурсу ваша учётная запись должна быть активирована",
+		"error.not_permitted":           "у вашей учётной записи нет прав для доступа к этому ресурсу",
+		"error.job_not_retryable":       "повторить можно только задания, исчерпавшие все попытки",
+		"error.email_not_retryable":     "повторно отправить можно только недоставленные письма",
+		"error.import_rows_invalid":     "головоломки не импортированы, так как некоторые строки содержат ошибки",
+
+		"message.puzzle_trashed":           "головоломка перемещена в корзину",
+		"message.unsubscribed":             "вы больше не будете получать эти письма",
+		"message.confirm_unsubscribe":      "отправьте POST-запрос на эту ссылку, чтобы больше не получать эти письма",
+		"message.review_deleted":           "отзыв удалён",
+		"message.favorite_removed":         "головоломка удалена из избранного",
+		"message.collection_deleted":       "подборка удалена",
+		"message.collection_entry_removed": "запись удалена из подборки",
+
+		"validation.required":                "обязательное поле",
+		"validation.max_bytes":               "должно быть не длиннее %d байт",
+		"validation.min_bytes":               "должно быть не короче %d байт",
+		"validation.exact_bytes":             "должно быть длиной %d байт",
+		"validation.positive":                "должно быть положительным целым числом",
+		"validation.greater_than_zero":       "должно быть больше нуля",
+		"validation.max_value":               "должно быть не больше %d",
+		"validation.max_page":                "должно быть не больше 10 миллионов",
+		"validation.between":                 "должно быть от %d до %d",
+		"validation.one_of":                  "должно быть одним из значений: %s",
+		"validation.integer":                 "должно быть целым числом",
+		"validation.json_type":               "имеет неверный тип JSON",
+		"validation.num_of_puzzles_format":   "должно быть в формате \"<n> puzzles\"",
+		"validation.number":                  "должно быть числом",
+		"validation.boolean":                 "должно быть логическим значением",
+		"validation.duplicates":              "не должно содержать повторяющихся значений",
+		"validation.invalid":                 "недопустимое значение: %v",
+		"validation.unreadable":              "не удалось прочитать: %v",
+		"validation.email":                   "должно быть корректным адресом электронной почты",
+		"validation.email_taken":             "пользователь с таким адресом электронной почты уже существует",
+		"validation.activation_token":        "недействительный или просроченный токен активации",
+		"validation.unsubscribe_token":       "недействительная ссылка для отписки",
+		"validation.min_genres":              "должно содержать хотя бы один жанр",
+		"validation.max_genres":              "должно содержать не более %d жанров",
+		"validation.min_roles":               "должно содержать хотя бы одну роль",
+		"validation.unknown_roles":           "должно содержать только известные роли",
+		"validation.min_codes":               "должно содержать хотя бы один код разрешения",
+		"validation.unknown_codes":           "должно содержать только известные коды разрешений",
+		"validation.unknown_genres":          "должно содержать только известные жанры, неизвестны: %s",
+		"validation.genre_taken":             "уже используется другим жанром",
+		"validation.slug":                    "должно состоять из строчных букв и цифр, разделённых одиночными дефисами",
+		"validation.sort":                    "недопустимое значение сортировки",
+		"validation.cursor":                  "должно быть корректным курсором",
+		"validation.cursor_with_page":        "нельзя использовать вместе с курсором",
+		"validation.cursor_sort":             "должно использоваться с той же сортировкой, для которой курсор был выдан",
+		"validation.status":                  "недопустимое значение статуса",
+		"validation.job_status":              "недопустимый статус задания",
+		"validation.email_status":            "недопустимый статус письма",
+		"validation.published_only":          "можно просматривать только опубликованные головоломки",
+		"validation.image_type":              "должно быть изображением PNG, JPEG или GIF",
+		"validation.image_decode":            "не удалось декодировать",
+		"validation.image_pixels":            "должно быть не больше %d мегапикселей",
+		"validation.no_solution":             "недоступно для этого элемента",
+		"validation.not_printable":           "не может быть подготовлено для печати",
+		"validation.pdf_text":                "нельзя использовать для этого элемента, его текст не в Latin-1; используйте svg",
+		"validation.review_exists":           "вы уже оставили отзыв об этой головоломке",
+		"validation.review_published":        "отзывы можно оставлять только об опубликованных головоломках",
+		"validation.review_own":              "нельзя оставить отзыв о собственной головоломке",
+		"validation.not_found":               "не существует",
+		"validation.collection_entry_exists": "уже есть в этой подборке",
+	},
+	Kazakh: {
+		"error.server":                  "серверде ақау орын алды, сұрауыңыз өңделмеді",
+		"error.not_found":               "сұралған ресурс табылмады",
+		"error.method_not_allowed":      "бұл ресурс үшін %s әдісіне қолдау көрсетілмейді",
+		"error.edit_conflict":           "өзгерістер қақтығысына байланысты жазбаны жаңарту мүмкін болмады, қайталап көріңіз",
+		"error.precondition_failed":     "ресурс соңғы сұраудан бері өзгерді, оны қайта сұраңыз",
+		"error.invalid_transition":      "басқатырғышты %s күйінен %s күйіне ауыстыру мүмкін емес",
+		"error.rate_limit":              "сұраулар шегінен асып кетті",
+		"error.invalid_credentials":     "тіркелгі деректері қате",
+		"error.invalid_token":           "аутентификация токені қате немесе жоқ",
+		"error.authentication_required": "бұл ресурсқа қол жеткізу үшін аутентификациядан өту қажет",
+		"error.inactive_account":        "бұл ресурсқа қол жеткізу үшін тіркелгіңіз белсендірілуі тиіс",
+		"error.not_permitted":           "тіркелгіңізде бұл ресурсқа қол жеткізуге рұқсат жоқ",
+		"error.job_not_retryable":       "тек барлық әрекеттері таусылған тапсырмаларды қайталауға болады",
+		"error.email_not_retryable":     "тек жеткізілмеген хаттарды қайта жіберуге болады",
+		"error.import_rows_invalid":     "кейбір жолдар қате болғандықтан, басқатырғыштар импортталмады",
+
+		"message.puzzle_trashed":           "басқатырғыш себетке жылжытылды",
+		"message.unsubscribed":             "бұдан былай бұл хаттарды алмайсыз",
+		"message.confirm_unsubscribe":      "бұл хаттарды алмау үшін осы сілтемеге POST сұрауын жіберіңіз",
+		"message.review_deleted":           "пікір жойылды",
+		"message.favorite_removed":         "басқатырғыш таңдаулылардан өшірілді",
+		"message.collection_deleted":       "жинақ жойылды",
+		"message.collection_entry_removed": "жазба жинақтан өшірілді",
+
+		"validation.required":                "міндетті өріс",
+		"validation.max_bytes":               "ұзындығы %d байттан аспауы тиіс",
+		"validation.min_bytes":               "ұзындығы кемінде %d байт болуы тиіс",
+		"validation.exact_bytes":             "ұзындығы %d байт болуы тиіс",
+		"validation.positive":                "оң бүтін сан болуы тиіс",
+		"validation.greater_than_zero":       "нөлден үлкен болуы тиіс",
+		"validation.max_value":               "%d мәнінен аспауы тиіс",
+		"validation.max_page":                "10 миллионнан аспауы тиіс",
+		"validation.between":                 "%d мен %d аралығында болуы тиіс",
+		"validation.one_of":                  "мына мәндердің бірі болуы тиіс: %s",
+		"validation.integer":                 "бүтін сан болуы тиіс",
+		"validation.json_type":               "JSON түрі дұрыс емес",
+		"validation.num_of_puzzles_format":   "\"<n> puzzles\" пішімінде болуы тиіс",
+		"validation.number":                  "сан болуы тиіс",
+		"validation.boolean":                 "логикалық мән болуы тиіс",
+		"validation.duplicates":              "қайталанатын мәндер болмауы тиіс",
+		"validation.invalid":                 "жарамсыз мән: %v",
+		"validation.unreadable":              "оқу мүмкін болмады: %v",
+		"validation.email":                   "жарамды электрондық пошта мекенжайы болуы тиіс",
+		"validation.email_taken":             "бұл электрондық пошта мекенжайымен тіркелген пайдаланушы бар",
+		"validation.activation_token":        "белсендіру токені жарамсыз немесе мерзімі өткен",
+		"validation.unsubscribe_token":       "жазылымнан бас тарту сілтемесі жарамсыз",
+		"validation.min_genres":              "кемінде бір жанр болуы тиіс",
+		"validation.max_genres":              "%d жанрдан аспауы тиіс",
+		"validation.min_roles":               "кемінде бір рөл болуы тиіс",
+		"validation.unknown_roles":           "тек белгілі рөлдер болуы тиіс",
+		"validation.min_codes":               "кемінде бір рұқсат коды болуы тиіс",
+		"validation.unknown_codes":           "тек белгілі рұқсат кодтары болуы тиіс",
+		"validation.unknown_genres":          "тек белгілі жанрлар болуы тиіс, белгісіздері: %s",
+		"validation.genre_taken":             "басқа жанрда қолданылып тұр",
+		"validation.slug":                    "бір сызықшамен бөлінген кіші әріптер мен сандардан тұруы тиіс",
+		"validation.sort":                    "сұрыптау мәні жарамсыз",
+		"validation.cursor":                  "жарамды курсор болуы тиіс",
+		"validation.cursor_with_page":        "курсормен бірге қолдануға болмайды",
+		"validation.cursor_sort":             "курсор берілген сұрыптау мәнімен бірге қолданылуы тиіс",
+		"validation.status":                  "күй мәні жарамсыз",
+		"validation.job_status":              "тапсырма күйі жарамсыз",
+		"validation.email_status":            "хат күйі жарамсыз",
+		"validation.published_only":          "тек жарияланған басқатырғыштарды тізімдеуге болады",
+		"validation.image_type":              "PNG, JPEG немесе GIF суреті болуы тиіс",
+		"validation.image_decode":            "декодтау мүмкін болмады",
+		"validation.image_pixels":            "%d мегапиксельден аспауы тиіс",
+		"validation.no_solution":             "бұл элемент үшін қолжетімсіз",
+		"validation.not_printable":           "басып шығаруға дайындау мүмкін емес",
+		"validation.pdf_text":                "бұл элемент үшін қолданылмайды, оның мәтіні Latin-1 емес; svg пайдаланыңыз",
+		"validation.review_exists":           "сіз бұл басқатырғышқа пікір қалдырып қойғансыз",
+		"validation.review_published":        "тек жарияланған басқатырғыштарға пікір қалдыруға болады",
+		"validation.review_own":              "өз басқатырғышыңызға пікір қалдыруға болмайды",
+		"validation.not_found":               "жоқ",
+		"validation.collection_entry_exists": "бұл жинақта бұрыннан бар",
+	},
+}
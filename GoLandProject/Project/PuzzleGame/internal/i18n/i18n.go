@@ -0,0 +1,82 @@
+// Package i18n translates the messages the API returns. Messages are looked up
+// by key in a catalogue per locale, falling back to English and then to the
+// key itself, so a missing translation never hides what went wrong.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	English = "en"
+	Russian = "ru"
+	Kazakh  = "kk"
+)
+
+// DefaultLocale is used when neither the request nor the user asks for a
+// supported locale.
+const DefaultLocale = English
+
+var Locales = []string{English, Russian, Kazakh}
+
+// Supported reports whether there is a catalogue for locale.
+func Supported(locale string) bool {
+	_, ok := catalogue[locale]
+	return ok
+}
+
+// T returns the message for key in locale, formatted with args as by
+// fmt.Sprintf.
+func T(locale, key string, args ...interface{}) string {
+	message, ok := catalogue[locale][key]
+	if !ok {
+		message, ok = catalogue[DefaultLocale][key]
+	}
+	if !ok {
+		message = key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Negotiate picks the supported locale the client prefers most from an
+// Accept-Language header such as "kk-KZ, ru;q=0.9, en;q=0.8". Region
+// subtags are ignored. It returns "" when nothing in the header is
+// supported.
+func Negotiate(acceptLanguage string) string {
+	type choice struct {
+		locale string
+		q      float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(name, "q") {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err == nil {
+					q = parsed
+				}
+			}
+		}
+		primary, _, _ := strings.Cut(tag, "-")
+		locale := strings.ToLower(strings.TrimSpace(primary))
+		if q > 0 && Supported(locale) {
+			choices = append(choices, choice{locale: locale, q: q})
+		}
+	}
+	if len(choices) == 0 {
+		return ""
+	}
+	sort.SliceStable(choices, func(i, j int) bool {
+		return choices[i].q > choices[j].q
+	})
+	return choices[0].locale
+}
@@ -0,0 +1,74 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"ru", Russian},
+		{"kk-KZ", Kazakh},
+		{"KK-kz, en;q=0.5", Kazakh},
+		{"en;q=0.8, ru;q=0.9", Russian},
+		{"de, fr;q=0.9, kk;q=0.1", Kazakh},
+		{"ru;q=0, en;q=0.1", English},
+		{"de, fr", ""},
+		{"en;q=0.5, ru;q=0.5", English},
+		{"ru;q=abc, en;q=0.9", Russian},
+		{" kk ; q=0.7 , ru ; Q=0.6", Kazakh},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Fatalf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T(Russian, "error.not_found"); got != catalogue[Russian]["error.not_found"] {
+		t.Fatalf("got %q for a translated key", got)
+	}
+	if got := T(Kazakh, "error.method_not_allowed", "PUT"); !strings.Contains(got, "PUT") {
+		t.Fatalf("arguments were not formatted into %q", got)
+	}
+	// Unknown locales and keys missing from a locale use English.
+	if got := T("de", "error.not_found"); got != catalogue[English]["error.not_found"] {
+		t.Fatalf("got %q for an unknown locale", got)
+	}
+	saved := catalogue[Kazakh]["error.not_found"]
+	delete(catalogue[Kazakh], "error.not_found")
+	defer func() { catalogue[Kazakh]["error.not_found"] = saved }()
+	if got := T(Kazakh, "error.not_found"); got != catalogue[English]["error.not_found"] {
+		t.Fatalf("got %q for a key missing from the locale", got)
+	}
+	// Keys missing everywhere are returned as they are, arguments and all.
+	if got := T(English, "error.no_such_key"); got != "error.no_such_key" {
+		t.Fatalf("got %q for a missing key", got)
+	}
+	if got := T(English, "error.no_such_key", 1); !strings.HasPrefix(got, "error.no_such_key") {
+		t.Fatalf("got %q for a missing key with arguments", got)
+	}
+}
+
+func TestCataloguesAreComplete(t *testing.T) {
+	for _, locale := range Locales {
+		if !Supported(locale) {
+			t.Fatalf("locale %s has no catalogue", locale)
+		}
+		for key := range catalogue[DefaultLocale] {
+			if _, ok := catalogue[locale][key]; !ok {
+				t.Fatalf("locale %s lacks %s", locale, key)
+			}
+		}
+		for key := range catalogue[locale] {
+			if _, ok := catalogue[DefaultLocale][key]; !ok {
+				t.Fatalf("locale %s has %s, which English lacks", locale, key)
+			}
+		}
+	}
+}
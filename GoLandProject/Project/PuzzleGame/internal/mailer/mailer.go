@@ -3,57 +3,99 @@ package mailer
 import (
 	"bytes"
 	"embed"
-	"github.com/go-mail/mail/v2"
 	"html/template"
+	"io/fs"
+	"path"
+	"strings"
 	"time"
 )
 
 //go:embed "templates"
 var templateFS embed.FS
 
+// defaultLocale holds the complete set of templates. It matches
+// i18n.DefaultLocale.
+const defaultLocale = "en"
+
+// Mailer renders emails from the embedded templates and hands them to a
+// Transport for delivery.
 type Mailer struct {
-	dialer *mail.Dialer
-	sender string
+	transport Transport
+	sender    string
 }
 
-func New(host string, port int, username, password, sender string) Mailer {
-	dialer := mail.NewDialer(host, port, username, password)
-	dialer.Timeout = 5 * time.Second
+func New(transport Transport, sender string) Mailer {
 	return Mailer{
-		dialer: dialer,
-		sender: sender,
+		transport: transport,
+		sender:    sender,
 	}
 }
-func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+
+func (m Mailer) Send(locale, recipient, templateFile string, data interface{}) error {
+	msg, err := m.Render(locale, recipient, templateFile, data)
 	if err != nil {
 		return err
 	}
+	return m.transport.Send(msg)
+}
+
+// Render executes the subject, plainBody, htmlBody and, when it is defined,
+// listUnsubscribe templates of templateFile without sending the result. The
+// template is taken from the directory of the given locale, or from the
+// English one when it has not been translated.
+func (m Mailer) Render(locale, recipient, templateFile string, data interface{}) (*Message, error) {
+	name := path.Join("templates", locale, templateFile)
+	if _, err := fs.Stat(templateFS, name); err != nil {
+		name = path.Join("templates", defaultLocale, templateFile)
+	}
+	tmpl, err := template.New("email").ParseFS(templateFS, name)
+	if err != nil {
+		return nil, err
+	}
 	subject := new(bytes.Buffer)
 	err = tmpl.ExecuteTemplate(subject, "subject", data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	plainBody := new(bytes.Buffer)
 	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
-	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	htmlBody := new(bytes.Buffer)
 	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// Templates for emails people can opt out of define listUnsubscribe.
+	unsubscribe := new(bytes.Buffer)
+	if tmpl.Lookup("listUnsubscribe") != nil {
+		err = tmpl.ExecuteTemplate(unsubscribe, "listUnsubscribe", data)
+		if err != nil {
+			return nil, err
+		}
 	}
-	msg := mail.NewMessage()
-	msg.SetHeader("To", recipient)
-	msg.SetHeader("From", m.sender)
-	msg.SetHeader("Subject", subject.String())
-	msg.SetBody("text/plain", plainBody.String())
-	msg.AddAlternative("text/html", htmlBody.String())
-	err = m.dialer.DialAndSend(msg)
+	return &Message{
+		From:            m.sender,
+		To:              recipient,
+		Subject:         subject.String(),
+		PlainBody:       plainBody.String(),
+		HTMLBody:        htmlBody.String(),
+		ListUnsubscribe: strings.TrimSpace(unsubscribe.String()),
+		Date:            time.Now(),
+	}, nil
+}
+
+// Templates lists the names of the embedded email templates. Every template
+// exists in English; other locales may only translate some of them.
+func Templates() ([]string, error) {
+	paths, err := fs.Glob(templateFS, path.Join("templates", defaultLocale, "*.tmpl"))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = path.Base(p)
 	}
-	return nil
+	return names, nil
 }
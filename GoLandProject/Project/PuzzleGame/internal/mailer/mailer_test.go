@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSendThroughMemoryTransport(t *testing.T) {
+	mem := NewMemoryTransport(0)
+	m := New(mem, "Puzzles <no-reply@puzzles.test>")
+	data := map[string]interface{}{"userID": 42, "activationToken": "ABC123"}
+	err := m.Send("en", "alice@example.com", "user_welcome.tmpl", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := mem.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.From != "Puzzles <no-reply@puzzles.test>" || msg.To != "alice@example.com" {
+		t.Fatalf("unexpected addresses %q -> %q", msg.From, msg.To)
+	}
+	if msg.Subject != "Welcome to Puzzles!" {
+		t.Fatalf("unexpected subject %q", msg.Subject)
+	}
+	if !strings.Contains(msg.PlainBody, "your user ID number is 42") || !strings.Contains(msg.PlainBody, `{"token": "ABC123"}`) {
+		t.Fatalf("plain body lacks the template data:\n%s", msg.PlainBody)
+	}
+	if !strings.Contains(msg.HTMLBody, "<p>For future reference, your user ID number is 42.</p>") || !strings.Contains(msg.HTMLBody, "ABC123") {
+		t.Fatalf("HTML body lacks the template data:\n%s", msg.HTMLBody)
+	}
+	if msg.ListUnsubscribe != "" {
+		t.Fatalf("welcome email has an unsubscribe link %q", msg.ListUnsubscribe)
+	}
+	if msg.Date.IsZero() {
+		t.Fatalf("message has no date")
+	}
+
+	mem.Reset()
+	if len(mem.Messages()) != 0 {
+		t.Fatalf("Reset kept messages")
+	}
+}
+
+func TestRenderLocale(t *testing.T) {
+	m := New(NewMemoryTransport(0), "no-reply@puzzles.test")
+	data := map[string]interface{}{"userID": 1, "activationToken": "T"}
+	msg, err := m.Render("ru", "a@example.com", "user_welcome.tmpl", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Добро пожаловать в Puzzles!" {
+		t.Fatalf("unexpected Russian subject %q", msg.Subject)
+	}
+	// Locales without a translation fall back to English.
+	msg, err = m.Render("de", "a@example.com", "user_welcome.tmpl", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Welcome to Puzzles!" {
+		t.Fatalf("unexpected fallback subject %q", msg.Subject)
+	}
+	if _, err := m.Render("en", "a@example.com", "missing.tmpl", data); err == nil {
+		t.Fatalf("expected an error for a missing template")
+	}
+}
+
+func TestRenderListUnsubscribe(t *testing.T) {
+	m := New(NewMemoryTransport(0), "no-reply@puzzles.test")
+	msg, err := m.Render("en", "a@example.com", "weekly_digest.tmpl", map[string]interface{}{
+		"name":           "Alice",
+		"unsubscribeURL": "https://puzzles.test/v1/notifications/unsubscribe?token=abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ListUnsubscribe != "https://puzzles.test/v1/notifications/unsubscribe?token=abc" {
+		t.Fatalf("unexpected unsubscribe link %q", msg.ListUnsubscribe)
+	}
+}
+
+func TestDirTransport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	transport, err := NewDirTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(transport, "no-reply@puzzles.test")
+	err = m.Send("en", "a@example.com", "weekly_digest.tmpl", map[string]interface{}{
+		"name":           "Alice",
+		"unsubscribeURL": "https://puzzles.test/unsubscribe",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	eml := string(raw)
+	for _, want := range []string{
+		"To: a@example.com",
+		"From: no-reply@puzzles.test",
+		"Subject: Your week on Puzzles",
+		"List-Unsubscribe: <https://puzzles.test/unsubscribe>",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		"Content-Type: text/plain",
+		"Content-Type: text/html",
+	} {
+		if !strings.Contains(eml, want) {
+			t.Fatalf("message file lacks %q:\n%s", want, eml)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/go-mail/mail/v2"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is a rendered email, ready to be delivered. ListUnsubscribe is the
+// URL mail clients can POST to for a one-click unsubscribe, as in RFC 8058.
+type Message struct {
+	From            string    `json:"from"`
+	To              string    `json:"to"`
+	Subject         string    `json:"subject"`
+	PlainBody       string    `json:"plain_body"`
+	HTMLBody        string    `json:"html_body"`
+	ListUnsubscribe string    `json:"list_unsubscribe,omitempty"`
+	Date            time.Time `json:"date"`
+}
+
+func (msg *Message) mime() *mail.Message {
+	m := mail.NewMessage()
+	m.SetHeader("To", msg.To)
+	m.SetHeader("From", msg.From)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetDateHeader("Date", msg.Date)
+	if msg.ListUnsubscribe != "" {
+		m.SetHeader("List-Unsubscribe", "<"+msg.ListUnsubscribe+">")
+		m.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	m.SetBody("text/plain", msg.PlainBody)
+	m.AddAlternative("text/html", msg.HTMLBody)
+	return m
+}
+
+// Transport delivers rendered messages.
+type Transport interface {
+	Send(msg *Message) error
+}
+
+// SMTPTransport delivers messages through an SMTP server.
+type SMTPTransport struct {
+	dialer *mail.Dialer
+}
+
+func NewSMTPTransport(host string, port int, username, password string) *SMTPTransport {
+	dialer := mail.NewDialer(host, port, username, password)
+	dialer.Timeout = 5 * time.Second
+	return &SMTPTransport{dialer: dialer}
+}
+
+func (t *SMTPTransport) Send(msg *Message) error {
+	return t.dialer.DialAndSend(msg.mime())
+}
+
+// DirTransport writes every message to its own .eml file in a directory,
+// where it can be opened with any mail client.
+type DirTransport struct {
+	dir string
+}
+
+func NewDirTransport(dir string) (*DirTransport, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &DirTransport{dir: dir}, nil
+}
+
+func (t *DirTransport) Send(msg *Message) error {
+	// The nanosecond timestamp keeps files in the order they were sent; the
+	// temp file suffix keeps two messages sent at once apart.
+	f, err := os.CreateTemp(t.dir, fmt.Sprintf("%d-*.eml", msg.Date.UnixNano()))
+	if err != nil {
+		return err
+	}
+	_, err = msg.mime().WriteTo(f)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	return f.Close()
+}
+
+// MemoryTransport keeps sent messages in memory instead of delivering them,
+// so that tests can assert on what was sent. Only the most recent limit
+// messages are kept, or all of them when limit is 0.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	limit    int
+	messages []*Message
+}
+
+func NewMemoryTransport(limit int) *MemoryTransport {
+	return &MemoryTransport{limit: limit}
+}
+
+func (t *MemoryTransport) Send(msg *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, msg)
+	if t.limit > 0 && len(t.messages) > t.limit {
+		t.messages = append([]*Message(nil), t.messages[len(t.messages)-t.limit:]...)
+	}
+	return nil
+}
+
+// Messages returns the kept messages, oldest first.
+func (t *MemoryTransport) Messages() []*Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Message(nil), t.messages...)
+}
+
+// Reset forgets every kept message.
+func (t *MemoryTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = nil
+}
+
+// Capture returns a transport that delivers through t and also keeps a copy
+// of every delivered message in mem.
+func Capture(t Transport, mem *MemoryTransport) Transport {
+	return captureTransport{next: t, mem: mem}
+}
+
+type captureTransport struct {
+	next Transport
+	mem  *MemoryTransport
+}
+
+func (t captureTransport) Send(msg *Message) error {
+	err := t.next.Send(msg)
+	if err != nil {
+		return err
+	}
+	return t.mem.Send(msg)
+}
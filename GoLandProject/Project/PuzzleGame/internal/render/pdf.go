@@ -0,0 +1,104 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// PDF writes the pages as a PDF 1.4 document. Text is set in the standard
+// Helvetica fonts, which every PDF reader provides, so no font is embedded.
+// Those fonts only cover Latin-1; other text fails with ErrUnsupportedText
+// before anything is written.
+func PDF(w io.Writer, pages []Page) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1 to 4 are the catalog, the page tree and the two fonts; each
+	// page then takes two objects, the page itself and its content stream.
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	var kids bytes.Buffer
+	for i := range pages {
+		fmt.Fprintf(&kids, "%d 0 R ", 5+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, 6+2*i))
+		content, err := pdfContent(page)
+		if err != nil {
+			return err
+		}
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// pdfContent converts a page to PDF drawing operators. PDF puts the origin at
+// the bottom-left corner, so every y coordinate is flipped.
+func pdfContent(page Page) ([]byte, error) {
+	var b bytes.Buffer
+	for _, r := range page.Rects {
+		fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f re f\n", r.X, PageHeight-r.Y-r.H, r.W, r.H)
+	}
+	b.WriteString("2 J\n")
+	for _, l := range page.Lines {
+		fmt.Fprintf(&b, "%g w %.2f %.2f m %.2f %.2f l S\n", l.Width, l.X1, PageHeight-l.Y1, l.X2, PageHeight-l.Y2)
+	}
+	for _, t := range page.Texts {
+		font := "F1"
+		if t.Bold {
+			font = "F2"
+		}
+		x := t.X
+		if t.Align == AlignCenter {
+			// Helvetica is narrower than the layout estimate on average;
+			// 0.55 centres digits and capitals well.
+			x -= float64(len([]rune(t.Value))) * t.Size * 0.55 / 2
+		}
+		value, err := pdfString(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "BT /%s %.2f Tf %.2f %.2f Td (", font, t.Size, x, PageHeight-t.Y)
+		b.Write(value)
+		b.WriteString(") Tj ET\n")
+	}
+	return b.Bytes(), nil
+}
+
+// pdfString encodes text for a literal string in WinAnsiEncoding. Characters
+// outside Latin-1 have no glyph in the standard fonts, so rather than print
+// them as '?' the text is rejected. Control characters draw nothing either
+// way and become '?'.
+func pdfString(s string) ([]byte, error) {
+	var b []byte
+	for _, r := range s {
+		switch {
+		case r > 0xff:
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedText, r)
+		case r == '(' || r == ')' || r == '\\':
+			b = append(b, '\\', byte(r))
+		case r < 0x20 || (r >= 0x7f && r < 0xa0):
+			b = append(b, '?')
+		default:
+			b = append(b, byte(r))
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,167 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"unicode"
+)
+
+// pngScale is the number of pixels per point, about 144 dpi.
+const pngScale = 2.0
+
+// PNG rasterises the pages one below the other into a grayscale image. Text
+// is drawn with a built-in 5x7 bitmap font that covers digits, capital
+// letters and common punctuation; lower-case letters are drawn as capitals.
+func PNG(w io.Writer, pages []Page) error {
+	return png.Encode(w, rasterize(pages, pngScale))
+}
+
+// PageImage rasterises a single page to an image of the given width, for
+// previews.
+func PageImage(page Page, width int) *image.Gray {
+	return rasterize([]Page{page}, float64(width)/PageWidth)
+}
+
+func rasterize(pages []Page, scale float64) *image.Gray {
+	width := int(PageWidth * scale)
+	pageHeight := int(PageHeight * scale)
+	rs := &raster{
+		img:   image.NewGray(image.Rect(0, 0, width, pageHeight*len(pages))),
+		scale: scale,
+	}
+	for i := range rs.img.Pix {
+		rs.img.Pix[i] = 0xff
+	}
+	for i, page := range pages {
+		offset := float64(i) * PageHeight
+		for _, r := range page.Rects {
+			rs.fillRect(r.X, r.Y+offset, r.X+r.W, r.Y+offset+r.H)
+		}
+		for _, l := range page.Lines {
+			half := l.Width / 2
+			rs.fillRect(math.Min(l.X1, l.X2)-half, math.Min(l.Y1, l.Y2)+offset-half,
+				math.Max(l.X1, l.X2)+half, math.Max(l.Y1, l.Y2)+offset+half)
+		}
+		for _, t := range page.Texts {
+			rs.drawText(t, offset)
+		}
+	}
+	return rs.img
+}
+
+type raster struct {
+	img   *image.Gray
+	scale float64
+}
+
+// fillRect paints the rectangle between two corners given in points, always
+// covering at least one pixel so that hairlines stay visible.
+func (rs *raster) fillRect(x0, y0, x1, y1 float64) {
+	rect := image.Rect(
+		int(math.Round(x0*rs.scale)), int(math.Round(y0*rs.scale)),
+		int(math.Round(x1*rs.scale)), int(math.Round(y1*rs.scale)),
+	)
+	if rect.Dx() == 0 {
+		rect.Max.X++
+	}
+	if rect.Dy() == 0 {
+		rect.Max.Y++
+	}
+	rect = rect.Intersect(rs.img.Rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			rs.img.SetGray(x, y, color.Gray{})
+		}
+	}
+}
+
+// drawText draws a glyph as dots of size/10 points, so capitals are 0.7 of
+// the font size tall and every character advances 0.6 of it, like charWidth.
+func (rs *raster) drawText(t Text, offset float64) {
+	dot := t.Size / 10
+	x := t.X
+	if t.Align == AlignCenter {
+		x -= textWidth(t.Value, t.Size) / 2
+	}
+	top := t.Y + offset - 7*dot
+	for _, r := range t.Value {
+		glyph, ok := font5x7[unicode.ToUpper(r)]
+		if !ok {
+			glyph = font5x7['?']
+			if unicode.IsSpace(r) {
+				glyph = [5]byte{}
+			}
+		}
+		for col, bits := range glyph {
+			for row := 0; row < 7; row++ {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+				px, py := x+float64(col)*dot, top+float64(row)*dot
+				right := px + dot
+				if t.Bold {
+					right += dot / 2
+				}
+				rs.fillRect(px, py, right, py+dot)
+			}
+		}
+		x += 6 * dot
+	}
+}
+
+// font5x7 holds one byte per column, least significant bit at the top.
+var font5x7 = map[rune][5]byte{
+	'0':  {0x3E, 0x51, 0x49, 0x45, 0x3E},
+	'1':  {0x00, 0x42, 0x7F, 0x40, 0x00},
+	'2':  {0x42, 0x61, 0x51, 0x49, 0x46},
+	'3':  {0x21, 0x41, 0x45, 0x4B, 0x31},
+	'4':  {0x18, 0x14, 0x12, 0x7F, 0x10},
+	'5':  {0x27, 0x45, 0x45, 0x45, 0x39},
+	'6':  {0x3C, 0x4A, 0x49, 0x49, 0x30},
+	'7':  {0x01, 0x71, 0x09, 0x05, 0x03},
+	'8':  {0x36, 0x49, 0x49, 0x49, 0x36},
+	'9':  {0x06, 0x49, 0x49, 0x29, 0x1E},
+	'A':  {0x7E, 0x11, 0x11, 0x11, 0x7E},
+	'B':  {0x7F, 0x49, 0x49, 0x49, 0x36},
+	'C':  {0x3E, 0x41, 0x41, 0x41, 0x22},
+	'D':  {0x7F, 0x41, 0x41, 0x22, 0x1C},
+	'E':  {0x7F, 0x49, 0x49, 0x49, 0x41},
+	'F':  {0x7F, 0x09, 0x09, 0x09, 0x01},
+	'G':  {0x3E, 0x41, 0x49, 0x49, 0x7A},
+	'H':  {0x7F, 0x08, 0x08, 0x08, 0x7F},
+	'I':  {0x00, 0x41, 0x7F, 0x41, 0x00},
+	'J':  {0x20, 0x40, 0x41, 0x3F, 0x01},
+	'K':  {0x7F, 0x08, 0x14, 0x22, 0x41},
+	'L':  {0x7F, 0x40, 0x40, 0x40, 0x40},
+	'M':  {0x7F, 0x02, 0x0C, 0x02, 0x7F},
+	'N':  {0x7F, 0x04, 0x08, 0x10, 0x7F},
+	'O':  {0x3E, 0x41, 0x41, 0x41, 0x3E},
+	'P':  {0x7F, 0x09, 0x09, 0x09, 0x06},
+	'Q':  {0x3E, 0x41, 0x51, 0x21, 0x5E},
+	'R':  {0x7F, 0x09, 0x19, 0x29, 0x46},
+	'S':  {0x46, 0x49, 0x49, 0x49, 0x31},
+	'T':  {0x01, 0x01, 0x7F, 0x01, 0x01},
+	'U':  {0x3F, 0x40, 0x40, 0x40, 0x3F},
+	'V':  {0x1F, 0x20, 0x40, 0x20, 0x1F},
+	'W':  {0x3F, 0x40, 0x38, 0x40, 0x3F},
+	'X':  {0x63, 0x14, 0x08, 0x14, 0x63},
+	'Y':  {0x07, 0x08, 0x70, 0x08, 0x07},
+	'Z':  {0x61, 0x51, 0x49, 0x45, 0x43},
+	'.':  {0x00, 0x60, 0x60, 0x00, 0x00},
+	',':  {0x00, 0x50, 0x30, 0x00, 0x00},
+	':':  {0x00, 0x36, 0x36, 0x00, 0x00},
+	';':  {0x00, 0x56, 0x36, 0x00, 0x00},
+	'-':  {0x08, 0x08, 0x08, 0x08, 0x08},
+	'!':  {0x00, 0x00, 0x5F, 0x00, 0x00},
+	'?':  {0x02, 0x01, 0x51, 0x09, 0x06},
+	'\'': {0x00, 0x05, 0x03, 0x00, 0x00},
+	'"':  {0x00, 0x07, 0x00, 0x07, 0x00},
+	'(':  {0x00, 0x1C, 0x22, 0x41, 0x00},
+	')':  {0x00, 0x41, 0x22, 0x1C, 0x00},
+	'/':  {0x20, 0x10, 0x08, 0x04, 0x02},
+	'&':  {0x36, 0x49, 0x55, 0x22, 0x50},
+	'#':  {0x14, 0x7F, 0x14, 0x7F, 0x14},
+}
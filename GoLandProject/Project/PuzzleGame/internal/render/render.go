@@ -0,0 +1,283 @@
+// Package render lays puzzle items out as printable A4 pages and draws those
+// pages as SVG, PDF or PNG. Everything is written with the standard library so
+// that rendering needs no fonts or system packages on the server.
+package render
+
+import (
+	"Puzzle.Ayan.net/internal/formats"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Page dimensions and margins in points, the unit used by every shape.
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+	margin     = 48.0
+)
+
+// charWidth approximates the advance of an average character as a fraction
+// of the font size. It is used to wrap and centre text, and matches the
+// bitmap font used for PNG output exactly.
+const charWidth = 0.6
+
+var ErrNoSolution = errors.New("item has no solution")
+
+// ErrUnsupportedText is returned by PDF for text its fonts cannot show.
+var ErrUnsupportedText = errors.New("text is not supported in PDF output")
+
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+)
+
+// Line is a straight stroke. Only horizontal and vertical lines are drawn by
+// the layouts, which keeps the raster backend simple.
+type Line struct {
+	X1, Y1, X2, Y2 float64
+	Width          float64
+}
+
+// Rect is a filled black rectangle.
+type Rect struct {
+	X, Y, W, H float64
+}
+
+// Text is a single line of text; Y is its baseline.
+type Text struct {
+	X, Y  float64
+	Size  float64
+	Align Align
+	Bold  bool
+	Value string
+}
+
+// Page holds the shapes of one page with the origin at its top-left corner.
+// Rects are drawn first, then lines, then text.
+type Page struct {
+	Rects []Rect
+	Lines []Line
+	Texts []Text
+}
+
+// Pages lays out an item. The puzzle comes first, followed by its solution on
+// a page of its own when solution is set.
+func Pages(item *formats.Item, solution bool) ([]Page, error) {
+	switch content := item.Content.(type) {
+	case *formats.Crossword:
+		return crosswordPages(item.Title, content, solution)
+	case *formats.Sudoku:
+		return sudokuPages(item.Title, content, solution)
+	default:
+		return nil, fmt.Errorf("%w: %T", formats.ErrUnsupported, item.Content)
+	}
+}
+
+func textWidth(s string, size float64) float64 {
+	return float64(utf8.RuneCountInString(s)) * size * charWidth
+}
+
+func (p *Page) title(title string) float64 {
+	p.Texts = append(p.Texts, Text{X: PageWidth / 2, Y: margin + 18, Size: 18, Align: AlignCenter, Bold: true, Value: title})
+	return margin + 40
+}
+
+// grid draws the ruling of a rows by cols grid of square cells, with every
+// boxth line drawn heavier (used for sudoku boxes).
+func (p *Page) grid(x, y, cell float64, rows, cols, box int) {
+	for row := 0; row <= rows; row++ {
+		width := 0.5
+		if row == 0 || row == rows || (box > 0 && row%box == 0) {
+			width = 2
+		}
+		yy := y + float64(row)*cell
+		p.Lines = append(p.Lines, Line{X1: x, Y1: yy, X2: x + float64(cols)*cell, Y2: yy, Width: width})
+	}
+	for col := 0; col <= cols; col++ {
+		width := 0.5
+		if col == 0 || col == cols || (box > 0 && col%box == 0) {
+			width = 2
+		}
+		xx := x + float64(col)*cell
+		p.Lines = append(p.Lines, Line{X1: xx, Y1: y, X2: xx, Y2: y + float64(rows)*cell, Width: width})
+	}
+}
+
+func crosswordPages(title string, c *formats.Crossword, solution bool) ([]Page, error) {
+	if solution {
+		for _, row := range c.Grid {
+			for _, value := range row {
+				if value == "" {
+					return nil, ErrNoSolution
+				}
+			}
+		}
+	}
+	cell := math.Min(32, math.Min((PageWidth-2*margin)/float64(c.Width), 460/float64(c.Height)))
+	drawGrid := func(p *Page, y float64, letters bool) float64 {
+		x := (PageWidth - cell*float64(c.Width)) / 2
+		numbers := c.Numbers()
+		for row := 0; row < c.Height; row++ {
+			for col := 0; col < c.Width; col++ {
+				cx, cy := x+float64(col)*cell, y+float64(row)*cell
+				if c.Grid[row][col] == formats.Block {
+					p.Rects = append(p.Rects, Rect{X: cx, Y: cy, W: cell, H: cell})
+					continue
+				}
+				if n := numbers[row][col]; n > 0 {
+					size := cell * 0.3
+					p.Texts = append(p.Texts, Text{X: cx + 2, Y: cy + size + 1, Size: size, Value: strconv.Itoa(n)})
+				}
+				if letters {
+					p.Texts = append(p.Texts, Text{X: cx + cell/2, Y: cy + cell*0.8, Size: cell * 0.55, Align: AlignCenter, Value: c.Grid[row][col]})
+				}
+			}
+		}
+		p.grid(x, y, cell, c.Height, c.Width, 0)
+		return y + cell*float64(c.Height)
+	}
+
+	var page Page
+	y := drawGrid(&page, page.title(title), false)
+	pages := flowClues(page, y+24, c)
+	if solution {
+		var page Page
+		drawGrid(&page, page.title(title+" - Solution"), true)
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// flowClues writes the clue lists in two columns starting below the grid,
+// continuing onto further pages as needed, and returns every page used.
+func flowClues(first Page, top float64, c *formats.Crossword) []Page {
+	const (
+		size    = 9.0
+		leading = 11.5
+		gutter  = 24.0
+	)
+	columnWidth := (PageWidth - 2*margin - gutter) / 2
+	pages := []Page{first}
+	column, y := 0, top
+	// reserve moves to the next column or page unless height more points fit
+	// in the current column.
+	reserve := func(height float64) {
+		if y+height <= PageHeight-margin {
+			return
+		}
+		if column == 0 {
+			column, y = 1, top
+			return
+		}
+		pages = append(pages, Page{})
+		column, top = 0, margin
+		y = top
+	}
+	emit := func(text Text) {
+		text.X += margin + float64(column)*(columnWidth+gutter)
+		text.Y += y + text.Size
+		pages[len(pages)-1].Texts = append(pages[len(pages)-1].Texts, text)
+	}
+	for _, list := range []struct {
+		heading string
+		clues   []formats.Clue
+	}{{"Across", c.Across}, {"Down", c.Down}} {
+		if len(list.clues) == 0 {
+			continue
+		}
+		if y > top {
+			y += leading / 2
+		}
+		// Keep the heading together with the first clue.
+		reserve(16 + leading)
+		emit(Text{Size: 11, Bold: true, Value: list.heading})
+		y += 16
+		indent := textWidth("000.", size)
+		for _, clue := range list.clues {
+			lines := wrap(clue.Text, columnWidth-indent, size)
+			reserve(float64(len(lines)) * leading)
+			emit(Text{Size: size, Bold: true, Value: strconv.Itoa(clue.Number) + "."})
+			for _, line := range lines {
+				emit(Text{X: indent, Size: size, Value: line})
+				y += leading
+			}
+		}
+	}
+	return pages
+}
+
+// wrap breaks text into lines no wider than width at the given size, splitting
+// words that do not fit on a line of their own.
+func wrap(text string, width, size float64) []string {
+	limit := int(width / (size * charWidth))
+	if limit < 1 {
+		limit = 1
+	}
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(text) {
+		w := []rune(word)
+		for len(w) > limit {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			lines = append(lines, string(w[:limit]))
+			w = w[limit:]
+		}
+		if len(line) > 0 && len(line)+1+len(w) > limit {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, w...)
+	}
+	if len(line) > 0 || len(lines) == 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+func sudokuPages(title string, s *formats.Sudoku, solution bool) ([]Page, error) {
+	if solution && s.Solution == nil {
+		return nil, ErrNoSolution
+	}
+	const cell = 44.0
+	drawGrid := func(p *Page, y float64, cells [][]int) {
+		x := (PageWidth - 9*cell) / 2
+		for row := range cells {
+			for col, value := range cells[row] {
+				if value == 0 {
+					continue
+				}
+				p.Texts = append(p.Texts, Text{
+					X:     x + float64(col)*cell + cell/2,
+					Y:     y + float64(row)*cell + cell*0.72,
+					Size:  cell * 0.55,
+					Align: AlignCenter,
+					Bold:  s.Cells[row][col] != 0,
+					Value: strconv.Itoa(value),
+				})
+			}
+		}
+		p.grid(x, y, cell, 9, 9, 3)
+	}
+
+	var page Page
+	drawGrid(&page, page.title(title), s.Cells)
+	pages := []Page{page}
+	if solution {
+		var page Page
+		drawGrid(&page, page.title(title+" - Solution"), s.Solution)
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
@@ -0,0 +1,197 @@
+package render
+
+import (
+	"Puzzle.Ayan.net/internal/formats"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testCrossword(clues int) *formats.Crossword {
+	c := &formats.Crossword{
+		Width:  3,
+		Height: 3,
+		Grid: [][]string{
+			{"C", "A", "T"},
+			{"A", formats.Block, "O"},
+			{"R", "A", "T"},
+		},
+	}
+	for i := 0; i < clues; i++ {
+		c.Across = append(c.Across, formats.Clue{Number: i + 1, Text: fmt.Sprintf("Clue number %d, long enough to wrap onto a second line of the column", i+1)})
+		c.Down = append(c.Down, formats.Clue{Number: i + 1, Text: "Ünïcödé and lower-case text"})
+	}
+	return c
+}
+
+func testSudoku(solution bool) *formats.Sudoku {
+	s := &formats.Sudoku{}
+	for row := 0; row < 9; row++ {
+		cells := make([]int, 9)
+		full := make([]int, 9)
+		for col := range full {
+			full[col] = (row*3+row/3+col)%9 + 1
+			if (row+col)%3 == 0 {
+				cells[col] = full[col]
+			}
+		}
+		s.Cells = append(s.Cells, cells)
+		if solution {
+			s.Solution = append(s.Solution, full)
+		}
+	}
+	return s
+}
+
+// checkBackends draws the pages with every backend and checks the output
+// describes the expected number of A4 pages.
+func checkBackends(t *testing.T, pages []Page) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := PNG(&buf, pages); err != nil {
+		t.Fatalf("PNG: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("PNG output does not decode: %v", err)
+	}
+	width, height := int(PageWidth*pngScale), int(PageHeight*pngScale)*len(pages)
+	if bounds := img.Bounds(); bounds.Dx() != width || bounds.Dy() != height {
+		t.Fatalf("PNG is %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), width, height)
+	}
+
+	buf.Reset()
+	if err := SVG(&buf, pages); err != nil {
+		t.Fatalf("SVG: %v", err)
+	}
+	header := fmt.Sprintf(`viewBox="0 0 %g %g"`, PageWidth, PageHeight*float64(len(pages)))
+	if !strings.Contains(buf.String(), header) || !strings.HasSuffix(strings.TrimSpace(buf.String()), "</svg>") {
+		t.Fatalf("SVG output lacks %s or its closing tag", header)
+	}
+
+	buf.Reset()
+	if err := PDF(&buf, pages); err != nil {
+		t.Fatalf("PDF: %v", err)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("PDF output is not a complete document")
+	}
+	if count := fmt.Sprintf("/Count %d", len(pages)); !strings.Contains(pdf, count) {
+		t.Fatalf("PDF page tree lacks %s", count)
+	}
+}
+
+func TestCrosswordPages(t *testing.T) {
+	item := &formats.Item{Kind: formats.KindCrossword, Title: "Crossword", Content: testCrossword(2)}
+	pages, err := Pages(item, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	if len(pages[0].Rects) != 1 {
+		t.Fatalf("got %d blocks, want 1", len(pages[0].Rects))
+	}
+	checkBackends(t, pages)
+
+	pages, err = Pages(item, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages with the solution, want 2", len(pages))
+	}
+	checkBackends(t, pages)
+}
+
+func TestCrosswordCluesOverflow(t *testing.T) {
+	item := &formats.Item{Kind: formats.KindCrossword, Title: "Crossword", Content: testCrossword(200)}
+	pages, err := Pages(item, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) < 2 {
+		t.Fatalf("200 clues fit on %d page", len(pages))
+	}
+	for i, page := range pages {
+		for _, text := range page.Texts {
+			if text.Y > PageHeight-margin+1 {
+				t.Fatalf("page %d has text below the bottom margin at %g", i+1, text.Y)
+			}
+		}
+	}
+	checkBackends(t, pages)
+}
+
+func TestCrosswordWithoutSolution(t *testing.T) {
+	c := testCrossword(1)
+	c.Grid[0][0] = ""
+	_, err := Pages(&formats.Item{Kind: formats.KindCrossword, Content: c}, true)
+	if !errors.Is(err, ErrNoSolution) {
+		t.Fatalf("expected ErrNoSolution, got %v", err)
+	}
+}
+
+func TestSudokuPages(t *testing.T) {
+	item := &formats.Item{Kind: formats.KindSudoku, Title: "Sudoku", Content: testSudoku(true)}
+	pages, err := Pages(item, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+	// 10 rules each way, plus the title.
+	if len(pages[0].Lines) != 20 {
+		t.Fatalf("got %d grid lines, want 20", len(pages[0].Lines))
+	}
+	if len(pages[1].Texts) != 81+1 {
+		t.Fatalf("solution page has %d texts, want 82", len(pages[1].Texts))
+	}
+	checkBackends(t, pages)
+
+	item.Content = testSudoku(false)
+	if _, err := Pages(item, true); !errors.Is(err, ErrNoSolution) {
+		t.Fatalf("expected ErrNoSolution, got %v", err)
+	}
+}
+
+func TestJigsawIsUnsupported(t *testing.T) {
+	item := &formats.Item{Kind: formats.KindJigsaw, Content: &formats.Jigsaw{Width: 100, Height: 100}}
+	if _, err := Pages(item, false); !errors.Is(err, formats.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestPageImage(t *testing.T) {
+	pages, err := Pages(&formats.Item{Kind: formats.KindSudoku, Content: testSudoku(false)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := PageImage(pages[0], 200)
+	scale := 200 / PageWidth
+	if bounds := img.Bounds(); bounds.Dx() != 200 || bounds.Dy() != int(PageHeight*scale) {
+		t.Fatalf("preview is %dx%d", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestPDFRejectsNonLatin1(t *testing.T) {
+	c := testCrossword(1)
+	c.Across[0].Text = "Столица Казахстана"
+	pages, err := Pages(&formats.Item{Kind: formats.KindCrossword, Title: "Crossword", Content: c}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := PDF(&buf, pages); !errors.Is(err, ErrUnsupportedText) {
+		t.Fatalf("expected ErrUnsupportedText, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("PDF wrote %d bytes before failing", buf.Len())
+	}
+}
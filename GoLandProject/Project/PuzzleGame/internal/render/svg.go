@@ -0,0 +1,42 @@
+package render
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// SVG draws the pages one below the other in a single SVG document.
+func SVG(w io.Writer, pages []Page) error {
+	bw := bufio.NewWriter(w)
+	height := PageHeight * float64(len(pages))
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%gpt" height="%gpt" viewBox="0 0 %g %g">`+"\n",
+		PageWidth, height, PageWidth, height)
+	fmt.Fprintf(bw, `<rect width="%g" height="%g" fill="#fff"/>`+"\n", PageWidth, height)
+	for i, page := range pages {
+		fmt.Fprintf(bw, `<g transform="translate(0 %g)" font-family="Helvetica, Arial, sans-serif">`+"\n", PageHeight*float64(i))
+		for _, r := range page.Rects {
+			fmt.Fprintf(bw, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f"/>`+"\n", r.X, r.Y, r.W, r.H)
+		}
+		for _, l := range page.Lines {
+			fmt.Fprintf(bw, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="#000" stroke-width="%g" stroke-linecap="square"/>`+"\n",
+				l.X1, l.Y1, l.X2, l.Y2, l.Width)
+		}
+		for _, t := range page.Texts {
+			fmt.Fprintf(bw, `<text x="%.2f" y="%.2f" font-size="%.2f"`, t.X, t.Y, t.Size)
+			if t.Align == AlignCenter {
+				io.WriteString(bw, ` text-anchor="middle"`)
+			}
+			if t.Bold {
+				io.WriteString(bw, ` font-weight="bold"`)
+			}
+			io.WriteString(bw, ">")
+			xml.EscapeText(bw, []byte(t.Value))
+			io.WriteString(bw, "</text>\n")
+		}
+		io.WriteString(bw, "</g>\n")
+	}
+	io.WriteString(bw, "</svg>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,53 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestThumbnailSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		wantW, wantH  int
+	}{
+		{640, 320, 320, 160},
+		{320, 640, 160, 320},
+		{1000, 1, 320, 1},
+		{1, 1000, 1, 320},
+		{100, 50, 100, 50},
+	}
+	for _, tt := range tests {
+		thumb := Thumbnail(image.NewGray(image.Rect(0, 0, tt.width, tt.height)), 320)
+		if bounds := thumb.Bounds(); bounds.Dx() != tt.wantW || bounds.Dy() != tt.wantH {
+			t.Fatalf("%dx%d scaled to %dx%d, want %dx%d", tt.width, tt.height, bounds.Dx(), bounds.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestThumbnailAverages(t *testing.T) {
+	// Columns alternate between black and white, so every target pixel
+	// covers one of each and comes out mid grey. The source does not start
+	// at the origin, which the scaler must allow for.
+	img := image.NewRGBA(image.Rect(10, 10, 18, 14))
+	for y := 10; y < 14; y++ {
+		for x := 10; x < 18; x++ {
+			c := color.RGBA{A: 0xff}
+			if x%2 == 1 {
+				c = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	thumb := Thumbnail(img, 4)
+	if bounds := thumb.Bounds(); bounds.Dx() != 4 || bounds.Dy() != 2 {
+		t.Fatalf("scaled to %dx%d, want 4x2", bounds.Dx(), bounds.Dy())
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if c := thumb.RGBAAt(x, y); c.R != 0x7f || c.G != 0x7f || c.B != 0x7f || c.A != 0xff {
+				t.Fatalf("pixel %d,%d is %v, want mid grey", x, y, c)
+			}
+		}
+	}
+}
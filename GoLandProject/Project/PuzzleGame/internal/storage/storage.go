@@ -0,0 +1,55 @@
+// Package storage keeps uploaded files outside the database. BlobStore is
+// deliberately small, with flat slash-separated keys, so that an S3-compatible
+// object store can stand in for the local filesystem implementation.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+type Info struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+type BlobStore interface {
+	// Put stores the contents of r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the blob stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key is usable with every store: non-empty
+// segments of letters, digits, '-', '_' and '.', separated by '/', with no
+// segment made up of dots only.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || strings.Trim(segment, ".") == "" {
+			return false
+		}
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	for _, key := range []string{
+		"jigsaw/2024/ab12cd.png",
+		"a",
+		"thumbnails/puzzle-1.v2.png",
+		"a_b/c-d/.hidden",
+	} {
+		if !ValidKey(key) {
+			t.Fatalf("ValidKey(%q) = false, want true", key)
+		}
+	}
+	for _, key := range []string{
+		"",
+		"..",
+		".",
+		"../etc/passwd",
+		"jigsaw/../../etc/passwd",
+		"jigsaw/./a.png",
+		"/etc/passwd",
+		"jigsaw/",
+		"jigsaw//a.png",
+		`jigsaw\a.png`,
+		`..\..\a.png`,
+		"C:/a.png",
+		"jigsaw/a b.png",
+		strings.Repeat("a", 513),
+	} {
+		if ValidKey(key) {
+			t.Fatalf("ValidKey(%q) = true, want false", key)
+		}
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s, err := NewFileStore(filepath.Join(root, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const key = "jigsaw/ab/cd.png"
+	if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put: expected ErrNotFound, got %v", err)
+	}
+	if err := s.Put(ctx, key, strings.NewReader("first"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, key, strings.NewReader("second"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, info, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "second" {
+		t.Fatalf("got %q, want %q", b, "second")
+	}
+	if info.ContentType != "image/png" || info.Size != int64(len("second")) {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "blobs", "jigsaw", "ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".upload-") {
+			t.Fatalf("temporary file %s left behind", entry.Name())
+		}
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete: expected ErrNotFound, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "blobs", "jigsaw", "ab", "cd.png.meta")); !os.IsNotExist(err) {
+		t.Fatalf("Delete left the sidecar file behind: %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("deleting a missing blob: %v", err)
+	}
+}
+
+func TestFileStoreRejectsInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s, err := NewFileStore(filepath.Join(root, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"../outside", "/outside", `..\outside`} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Put(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+		if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Get(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+		if err := s.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Delete(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside")); !os.IsNotExist(err) {
+		t.Fatalf("a blob was written outside the store: %v", err)
+	}
+}
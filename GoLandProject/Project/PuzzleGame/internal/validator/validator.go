@@ -8,27 +8,34 @@ var (
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 )
 
+// Error is a failed check. It holds a message key and its arguments rather
+// than text, so that it can be translated when the response is written.
+type Error struct {
+	Key  string
+	Args []interface{}
+}
+
 type Validator struct {
-	Errors map[string]string
+	Errors map[string]Error
 }
 
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{Errors: make(map[string]Error)}
 }
 
 func (v *Validator) Valid() bool {
 	return len(v.Errors) == 0
 }
 
-func (v *Validator) AddError(key, message string) {
-	if _, exists := v.Errors[key]; !exists {
-		v.Errors[key] = message
+func (v *Validator) AddError(field, key string, args ...interface{}) {
+	if _, exists := v.Errors[field]; !exists {
+		v.Errors[field] = Error{Key: key, Args: args}
 	}
 }
 
-func (v *Validator) Check(ok bool, key, message string) {
+func (v *Validator) Check(ok bool, field, key string, args ...interface{}) {
 	if !ok {
-		v.AddError(key, message)
+		v.AddError(field, key, args...)
 	}
 }
 
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS audit_log;
+DELETE FROM permissions WHERE code = 'admin:users';
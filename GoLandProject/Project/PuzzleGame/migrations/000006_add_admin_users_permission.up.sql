@@ -0,0 +1,11 @@
+INSERT INTO permissions (code)
+VALUES
+    ('admin:users');
+CREATE TABLE IF NOT EXISTS audit_log (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    actor_id bigint REFERENCES users ON DELETE SET NULL,
+    action text NOT NULL,
+    target_user_id bigint REFERENCES users ON DELETE SET NULL,
+    details jsonb NOT NULL DEFAULT '{}'
+);
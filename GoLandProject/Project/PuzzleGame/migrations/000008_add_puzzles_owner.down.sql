@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code = 'puzzles:write:any';
+DROP INDEX IF EXISTS puzzles_owner_id_idx;
+ALTER TABLE puzzles DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,8 @@
+ALTER TABLE puzzles ADD COLUMN IF NOT EXISTS owner_id bigint REFERENCES users ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS puzzles_owner_id_idx ON puzzles (owner_id);
+INSERT INTO permissions (code)
+VALUES
+    ('puzzles:write:any');
+INSERT INTO roles_permissions
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name IN ('moderator', 'admin') AND permissions.code = 'puzzles:write:any';
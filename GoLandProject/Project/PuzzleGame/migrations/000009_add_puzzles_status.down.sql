@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code = 'puzzles:moderate';
+DROP INDEX IF EXISTS puzzles_status_idx;
+ALTER TABLE puzzles DROP CONSTRAINT IF EXISTS puzzles_status_check;
+ALTER TABLE puzzles DROP COLUMN IF EXISTS status;
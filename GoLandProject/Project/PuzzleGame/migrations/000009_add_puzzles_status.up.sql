@@ -0,0 +1,10 @@
+ALTER TABLE puzzles ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published';
+ALTER TABLE puzzles ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE puzzles ADD CONSTRAINT puzzles_status_check CHECK (status IN ('draft', 'in_review', 'published', 'archived'));
+CREATE INDEX IF NOT EXISTS puzzles_status_idx ON puzzles (status);
+INSERT INTO permissions (code)
+VALUES
+    ('puzzles:moderate');
+INSERT INTO roles_permissions
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name IN ('moderator', 'admin') AND permissions.code = 'puzzles:moderate';
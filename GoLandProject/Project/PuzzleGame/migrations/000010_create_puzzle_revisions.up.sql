@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS puzzle_revisions (
+    id bigserial PRIMARY KEY,
+    puzzle_id bigint NOT NULL REFERENCES puzzles ON DELETE CASCADE,
+    version integer NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    title text NOT NULL,
+    num_of_puzzles integer NOT NULL,
+    genres text[] NOT NULL,
+    status text NOT NULL,
+    changes jsonb NOT NULL DEFAULT '{}',
+    UNIQUE (puzzle_id, version)
+);
+INSERT INTO puzzle_revisions (puzzle_id, version, user_id, created_at, title, num_of_puzzles, genres, status)
+SELECT id, version, owner_id, created_at, title, NumOfPuzzles, genres, status
+FROM puzzles;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS puzzles_deleted_at_idx;
+ALTER TABLE puzzles DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,2 @@
+ALTER TABLE puzzles ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+CREATE INDEX IF NOT EXISTS puzzles_deleted_at_idx ON puzzles (deleted_at) WHERE deleted_at IS NOT NULL;
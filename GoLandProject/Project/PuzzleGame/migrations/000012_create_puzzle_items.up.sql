@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS puzzle_items (
+    id bigserial PRIMARY KEY,
+    puzzle_id bigint NOT NULL REFERENCES puzzles ON DELETE CASCADE,
+    position integer NOT NULL,
+    kind text NOT NULL,
+    title text NOT NULL DEFAULT '',
+    content jsonb NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (puzzle_id, position)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE puzzle_items DROP COLUMN IF EXISTS thumbnail;
+ALTER TABLE puzzles DROP COLUMN IF EXISTS thumbnail;
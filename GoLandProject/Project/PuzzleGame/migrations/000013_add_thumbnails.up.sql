@@ -0,0 +1,2 @@
+ALTER TABLE puzzles ADD COLUMN IF NOT EXISTS thumbnail text NOT NULL DEFAULT '';
+ALTER TABLE puzzle_items ADD COLUMN IF NOT EXISTS thumbnail text NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+    name text PRIMARY KEY,
+    last_run_at timestamp(0) with time zone NOT NULL
+);
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS activated_at timestamp(0) with time zone;
+-- Before the column existed an account was only written again once it had
+-- been activated or edited by an admin, so that is the best record there is
+-- of which accounts were ever activated.
+UPDATE users SET activated_at = created_at WHERE activated OR version > 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS puzzle_revisions_published_idx;
+DROP TABLE IF EXISTS notification_settings;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    daily_reminder boolean NOT NULL DEFAULT false,
+    weekly_digest boolean NOT NULL DEFAULT false,
+    new_packs boolean NOT NULL DEFAULT false,
+    followed_genres text[] NOT NULL DEFAULT '{}',
+    daily_reminder_sent_at timestamp(0) with time zone,
+    weekly_digest_sent_at timestamp(0) with time zone,
+    new_packs_sent_at timestamp(0) with time zone,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS puzzle_revisions_published_idx ON puzzle_revisions (created_at) WHERE status = 'published';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS puzzles_rating_idx;
+ALTER TABLE puzzles DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE puzzles DROP COLUMN IF EXISTS rating_avg;
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS reviews (
+    id bigserial PRIMARY KEY,
+    puzzle_id bigint NOT NULL REFERENCES puzzles ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    rating smallint NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    UNIQUE (puzzle_id, user_id)
+);
+ALTER TABLE puzzles ADD COLUMN IF NOT EXISTS rating_avg numeric(3, 2) NOT NULL DEFAULT 0;
+ALTER TABLE puzzles ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS puzzles_rating_idx ON puzzles (rating_avg, id) WHERE deleted_at IS NULL;
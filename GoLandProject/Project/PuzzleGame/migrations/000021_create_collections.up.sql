@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS favorites (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    puzzle_id bigint NOT NULL REFERENCES puzzles ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, puzzle_id)
+);
+CREATE TABLE IF NOT EXISTS collections (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    public boolean NOT NULL DEFAULT false,
+    slug text NOT NULL UNIQUE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS collections_user_id_idx ON collections (user_id);
+-- Positions are shifted in place when an entry is inserted or removed, which
+-- only keeps them unique once the whole statement is done.
+CREATE TABLE IF NOT EXISTS collection_entries (
+    id bigserial PRIMARY KEY,
+    collection_id bigint NOT NULL REFERENCES collections ON DELETE CASCADE,
+    puzzle_id bigint NOT NULL REFERENCES puzzles ON DELETE CASCADE,
+    item_id bigint REFERENCES puzzle_items ON DELETE CASCADE,
+    position integer NOT NULL,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CONSTRAINT collection_entries_position_key UNIQUE (collection_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+CREATE UNIQUE INDEX IF NOT EXISTS collection_entries_target_idx ON collection_entries (collection_id, puzzle_id, COALESCE(item_id, 0));
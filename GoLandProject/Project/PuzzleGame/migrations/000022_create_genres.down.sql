@@ -0,0 +1,3 @@
+-- The genres of existing puzzles stay normalized; the original spellings
+-- only survive as aliases, which go with the table.
+DROP TABLE IF EXISTS genres;
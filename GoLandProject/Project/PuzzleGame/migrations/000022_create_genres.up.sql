@@ -0,0 +1,83 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    slug text NOT NULL UNIQUE,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    aliases text[] NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS genres_aliases_idx ON genres USING GIN (aliases);
+
+INSERT INTO genres (slug, name, description) VALUES
+    ('crossword', 'Crossword', 'Grids of words filled in from clues.'),
+    ('sudoku', 'Sudoku', 'Number placement on a 9 by 9 grid.'),
+    ('jigsaw', 'Jigsaw', 'Pictures cut into pieces to put back together.')
+ON CONFLICT (slug) DO NOTHING;
+
+-- genre_slug folds a free-text genre onto a slug: lower case, every run of
+-- other characters turned into one dash and a trailing "puzzle(s)" dropped,
+-- so that "Logic", "logic" and "logic-puzzles" become one genre. genreSlug in
+-- internal/data/genres.go applies the same rule to genres sent by clients.
+CREATE FUNCTION pg_temp.genre_slug(genre text) RETURNS text AS $$
+    SELECT COALESCE(NULLIF(regexp_replace(
+        trim(BOTH '-' FROM regexp_replace(lower(genre), '[^[:alnum:]]+', '-', 'g')),
+        '^(.+)-puzzles?$', '\1'), ''), 'other')
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE TEMPORARY TABLE used_genres AS
+    SELECT genre, pg_temp.genre_slug(genre) AS slug FROM (
+        SELECT unnest(genres) FROM puzzles
+        UNION SELECT unnest(genres) FROM puzzle_revisions
+        UNION SELECT unnest(followed_genres) FROM notification_settings
+    ) AS used (genre);
+
+-- Every genre in use gets a row, and the spellings that were folded onto it
+-- are kept as aliases so that clients sending them still work.
+INSERT INTO genres (slug, name)
+SELECT DISTINCT slug, initcap(replace(slug, '-', ' ')) FROM used_genres
+ON CONFLICT (slug) DO NOTHING;
+
+UPDATE genres SET aliases = ARRAY(
+    SELECT DISTINCT lower(trim(used_genres.genre)) FROM used_genres
+    WHERE used_genres.slug = genres.slug AND lower(trim(used_genres.genre)) NOT IN (genres.slug, '')
+    ORDER BY 1
+);
+
+-- Rewriting the genres is a change to the puzzle like any other edit, so a
+-- puzzle whose genres change gets a new version, which ETags handed out
+-- before no longer match, and a revision without a user recording it. The
+-- revisions already stored are history and keep their spellings; genres are
+-- resolved again when one of them is restored.
+CREATE TEMPORARY TABLE normalized_puzzles AS
+    SELECT id, genres AS old_genres, ARRAY(
+        SELECT slug FROM (
+            SELECT pg_temp.genre_slug(genre) AS slug, min(position) AS position
+            FROM unnest(puzzles.genres) WITH ORDINALITY AS listed (genre, position)
+            GROUP BY 1
+        ) AS normalized ORDER BY position
+    ) AS new_genres
+    FROM puzzles;
+
+WITH updated AS (
+    UPDATE puzzles SET genres = normalized_puzzles.new_genres, version = puzzles.version + 1
+    FROM normalized_puzzles
+    WHERE puzzles.id = normalized_puzzles.id AND normalized_puzzles.new_genres <> normalized_puzzles.old_genres
+    RETURNING puzzles.id, puzzles.version, puzzles.title, puzzles.NumOfPuzzles, puzzles.genres, puzzles.status, normalized_puzzles.old_genres
+)
+INSERT INTO puzzle_revisions (puzzle_id, version, title, num_of_puzzles, genres, status, changes)
+SELECT id, version, title, NumOfPuzzles, genres, status,
+    jsonb_build_object('genres', jsonb_build_object('from', to_jsonb(old_genres), 'to', to_jsonb(genres)))
+FROM updated;
+
+UPDATE notification_settings SET followed_genres = ARRAY(
+    SELECT slug FROM (
+        SELECT pg_temp.genre_slug(genre) AS slug, min(position) AS position
+        FROM unnest(notification_settings.followed_genres) WITH ORDINALITY AS listed (genre, position)
+        GROUP BY 1
+    ) AS normalized ORDER BY position
+);
+
+DROP TABLE normalized_puzzles;
+DROP TABLE used_genres;
+DROP FUNCTION pg_temp.genre_slug(text);